@@ -22,9 +22,14 @@ OTHER DEALINGS IN THE SOFTWARE.
 */
 
 import { HttpClient } from '@angular/common/http';
-import { Injectable } from '@angular/core';
+import { Injectable, NgZone } from '@angular/core';
+import { Observable } from 'rxjs';
 import { environment } from 'src/environments/environment';
 
+const TUS_VERSION = '1.0.0';
+const UPLOAD_CHUNK = 16 * 1024 * 1024;
+const UPLOAD_RETRIES = 8;
+
 @Injectable({
   providedIn: 'root'
 })
@@ -37,18 +42,117 @@ export class ApiService {
   private SEARCH = environment.baseAPI + 'search';
   private HISTORY = environment.baseAPI + 'history';
   private DELETE = environment.baseAPI + 'delete';
+  private SCHEMA = environment.baseAPI + 'schema';
+  private PREVIEW = environment.baseAPI + 'preview';
+  private DETECT = environment.baseAPI + 'detect';
+  private REPORT = environment.baseAPI + 'report';
+  private EVENTS = environment.baseAPI + 'events';
+  private CANCEL = environment.baseAPI + 'cancel';
+  private UPLOADS = environment.baseAPI + 'uploads';
+  private PAUSE = environment.baseAPI + 'pause';
+  private PRIORITY = environment.baseAPI + 'priority';
+  private RESUME = environment.baseAPI + 'resume';
 
   constructor(
-    private httpClient: HttpClient
+    private httpClient: HttpClient,
+    private zone: NgZone
   ) { }
 
+  public events(): Observable<any> {
+    const types = [
+      'queued', 'started', 'progress', 'completed', 'failed',
+      'paused', 'resumed', 'cancelled', 'deleting', 'deleted', 'duplicate'
+    ];
+    return new Observable(observer => {
+      const source = new EventSource(this.EVENTS);
+      types.forEach(type => {
+        source.addEventListener(type, (event: any) => {
+          this.zone.run(() => observer.next(JSON.parse(event.data)));
+        });
+      });
+      return () => source.close();
+    });
+  }
+
   public upload(data: FormData) {
     return this.httpClient.post(this.UPLOAD, data);
   }
 
-  public search(query: string, page: number) {
+  /*
+  Resumable upload (tus protocol), the file is sent in chunks and
+  interrupted chunks are sent again from the offset known to the
+  server. Emits the upload progress (percent).
+  */
+  public resumableUpload(file: File, metadata: { [key: string]: string }): Observable<number> {
+    const tus = { 'Tus-Resumable': TUS_VERSION };
+    const encoded = Object.keys(metadata)
+      .map(key => `${key} ${btoa(unescape(encodeURIComponent(metadata[key])))}`)
+      .join(',');
+
+    return new Observable<number>(observer => {
+      let stopped = false;
+      const upload = async () => {
+        const created = await this.httpClient.post(this.UPLOADS, null, {
+          headers: { ...tus, 'Upload-Length': String(file.size), 'Upload-Metadata': encoded },
+          observe: 'response'
+        }).toPromise();
+        const location = created.headers.get('Location') as string;
+
+        let offset = 0;
+        let failures = 0;
+        let resync = false;
+        let done = false;
+        while (!done && !stopped) {
+          try {
+            if (resync) {
+              const head = await this.httpClient.head(location, { headers: tus, observe: 'response' }).toPromise();
+              offset = Number(head.headers.get('Upload-Offset'));
+              resync = false;
+            }
+            const response = await this.httpClient.patch(location, file.slice(offset, offset + UPLOAD_CHUNK), {
+              headers: { ...tus, 'Upload-Offset': String(offset), 'Content-Type': 'application/offset+octet-stream' },
+              observe: 'response'
+            }).toPromise();
+            offset = Number(response.headers.get('Upload-Offset'));
+            done = offset >= file.size;
+            failures = 0;
+            observer.next(offset * 100 / file.size);
+          } catch (err) {
+            /* Client errors other than offset conflicts are final */
+            const retry = err.status === 0 || err.status === 409 || err.status >= 500;
+            if (!retry || ++failures > UPLOAD_RETRIES) {
+              throw err;
+            }
+            await new Promise(resolve => setTimeout(resolve, 1000 * 2 ** failures));
+            resync = true;
+          }
+        }
+      };
+
+      upload().then(
+        () => observer.complete(),
+        err => observer.error(err)
+      );
+      return () => { stopped = true; };
+    });
+  }
+
+  public schema(data: FormData) {
+    return this.httpClient.post(this.SCHEMA, data);
+  }
+
+  public preview(data: FormData) {
+    return this.httpClient.post(this.PREVIEW, data);
+  }
+
+  public detect(data: FormData) {
+    return this.httpClient.post(this.DETECT, data);
+  }
+
+  public search(query: string, page: number, field = '') {
     const data = {
       query,
+      field,
       page
     };
     return this.httpClient.post(this.SEARCH, data);
@@ -61,6 +165,37 @@ export class ApiService {
     return this.httpClient.post(this.HISTORY, data);
   }
 
+  public getReport(checksum: string) {
+    const data = {
+      checksum
+    };
+    return this.httpClient.post(this.REPORT, data);
+  }
+
+  public cancelJob(job: string, rollback: boolean) {
+    const data = {
+      job,
+      rollback
+    };
+    return this.httpClient.post(this.CANCEL, data);
+  }
+
+  public pauseJob(job: string) {
+    return this.httpClient.post(this.PAUSE, { job });
+  }
+
+  public resumeJob(job: string) {
+    return this.httpClient.post(this.RESUME, { job });
+  }
+
+  public prioritizeJob(job: string, priority: number) {
+    const data = {
+      job,
+      priority
+    };
+    return this.httpClient.post(this.PRIORITY, data);
+  }
+
   public delete(checkSum: string) {
     const data = {
       checkSum
@@ -1,4 +1,5 @@
 import { Component, OnDestroy, OnInit } from '@angular/core';
+import { Subscription } from 'rxjs';
 import { ApiService } from '../api.service';
 
 interface History {
@@ -6,6 +7,46 @@ interface History {
   filename: string;
   checksum: string;
   status: number;
+  encoding?: string;
+  report?: Report;
+  progress?: Progress;
+}
+
+interface Progress {
+  size: number;
+  bytes_read: number;
+  percent: number;
+  parsed: number;
+  indexed: number;
+  docs_per_second: number;
+  eta: number;
+  job: string;
+  paused: boolean;
+  queue: number;
+  priority: number;
+  workers: number;
+}
+
+interface Report {
+  lines: number;
+  parsed: number;
+  skipped: { [reason: string]: number };
+  indexed: number;
+  failed: number;
+  retries: number;
+  errors?: { [type: string]: number };
+  bytes: number;
+  seconds: number;
+  docs_per_second: number;
+  bytes_per_second: number;
+  rejected?: { line: number; raw: string; reason: string }[];
+}
+
+interface JobEvent {
+  type: string;
+  checksum: string;
+  status: number;
+  progress?: Progress;
 }
 
 interface HistoryData {
@@ -33,8 +74,16 @@ export class StatusComponent implements OnInit, OnDestroy {
   deleteModal = false;
   toDelete = '';
 
+  cancelModal = false;
+  toCancel = '';
+  rollback = false;
+
+  reportModal = false;
+  report: Report | null = null;
+
   errorMessage = 'Unable to retrieve history data';
   apiInterval: any;
+  eventsSubscription: Subscription | null = null;
 
   constructor(
     private apiService: ApiService
@@ -52,10 +101,42 @@ export class StatusComponent implements OnInit, OnDestroy {
     this.apiInterval = setInterval(
       () => { this.getHistory(); }, 30 * 1000
     );
+    this.eventsSubscription = this.apiService.events()
+      .subscribe((event: JobEvent) => this.onEvent(event));
   }
 
   ngOnDestroy(): void {
     clearInterval(this.apiInterval);
+    this.eventsSubscription?.unsubscribe();
+  }
+
+  public onEvent(event: JobEvent): void {
+    const history = this.uploadHistory.find(h => h.checksum === event.checksum);
+    switch (event.type) {
+      case 'progress':
+      case 'paused':
+      case 'resumed':
+        if (history) {
+          history.progress = event.progress;
+        }
+        break;
+      case 'completed':
+      case 'failed':
+      case 'cancelled':
+        if (history) {
+          history.status = event.status;
+          history.progress = undefined;
+        }
+        this.getHistory();
+        break;
+      case 'deleting':
+        if (history) {
+          history.status = event.status;
+        }
+        break;
+      default:
+        this.getHistory();
+    }
   }
 
   public getHistory(): void {
@@ -80,6 +161,69 @@ export class StatusComponent implements OnInit, OnDestroy {
       );
   }
 
+  public formatETA(seconds: number): string {
+    if (seconds < 0) {
+      return '-';
+    }
+    const h = Math.floor(seconds / 3600);
+    const m = Math.floor((seconds % 3600) / 60);
+    const s = Math.floor(seconds % 60);
+    return h > 0 ? `${h}h ${m}m` : m > 0 ? `${m}m ${s}s` : `${s}s`;
+  }
+
+  public onPause(progress: Progress): void {
+    const request = progress.paused
+      ? this.apiService.resumeJob(progress.job)
+      : this.apiService.pauseJob(progress.job);
+    request.subscribe(
+      _ => { progress.paused = !progress.paused; },
+      _ => { this.errorMessage = 'Unable to pause or resume the import'; this.historyError = true; }
+    );
+  }
+
+  public onPriority(progress: Progress): void {
+    this.apiService.prioritizeJob(progress.job, progress.priority + 1)
+      .subscribe(
+        _ => { this.getHistory(); },
+        _ => { this.errorMessage = 'Unable to change the import priority'; this.historyError = true; }
+      );
+  }
+
+  public onCancelRequest(job: string): void {
+    this.toCancel = job;
+    this.rollback = false;
+    this.cancelModal = true;
+  }
+
+  public onCancel(): void {
+    this.apiService.cancelJob(this.toCancel, this.rollback)
+      .subscribe(
+        _ => {
+          this.toCancel = '';
+          this.cancelModal = false;
+        },
+        _ => {
+          this.cancelModal = false;
+          this.errorMessage = 'Unable to cancel the import';
+          this.historyError = true;
+        }
+      );
+  }
+
+  public onReportRequest(checksum: string): void {
+    this.apiService.getReport(checksum)
+      .subscribe(
+        (data: Report) => {
+          this.report = data;
+          this.reportModal = true;
+        },
+        _ => {
+          this.errorMessage = 'Unable to retrieve import report';
+          this.historyError = true;
+        }
+      );
+  }
+
   public onDeleteRequest(checkSum: string): void {
     this.toDelete = checkSum;
     this.deleteModal = true;
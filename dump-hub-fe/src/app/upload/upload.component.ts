@@ -23,8 +23,47 @@ OTHER DEALINGS IN THE SOFTWARE.
 
 import { Component, OnInit } from '@angular/core';
 import { FormControl, FormGroup, Validators } from '@angular/forms';
+import { Subscription } from 'rxjs';
 import { ApiService } from '../api.service';
 
+/* Bytes of the file sample parsed by the preview */
+const PREVIEW_SIZE = 64 * 1024;
+/* Columns shown by the preview of positional formats */
+const PREVIEW_COLUMNS = 32;
+
+interface Table {
+  name: string;
+  columns: string[];
+  rows: string[][];
+}
+
+interface SkippedLine {
+  line: number;
+  raw: string;
+  reason: string;
+}
+
+interface DetectedColumn {
+  selector: string;
+  name: string;
+  type: string;
+  sample: string;
+}
+
+interface Detection {
+  kind: string;
+  config: { [key: string]: any };
+  header: boolean;
+  columns: DetectedColumn[];
+}
+
+interface Preview {
+  entries: { fields: { [name: string]: string } }[];
+  skipped_lines: SkippedLine[];
+  truncated: boolean;
+  error?: string;
+}
+
 @Component({
   selector: 'app-upload',
   templateUrl: './upload.component.html',
@@ -38,11 +77,30 @@ export class UploadComponent implements OnInit {
   });
 
   patternForm = new FormGroup({
+    format: new FormControl('', Validators.required),
     separator: new FormControl('', Validators.required),
-    commentChar: new FormControl('', Validators.required)
+    regex: new FormControl(false),
+    commentChar: new FormControl(''),
+    quote: new FormControl(''),
+    escape: new FormControl(''),
+    lineRegex: new FormControl(''),
+    table: new FormControl(''),
+    encoding: new FormControl(''),
+    maxLine: new FormControl(1024, Validators.min(1)),
+    longLines: new FormControl('skip'),
+    header: new FormControl(false),
+    priority: new FormControl(0),
+    workers: new FormControl(0, [Validators.min(0), Validators.max(64)])
   });
 
+  encodings = [
+    'utf-8', 'utf-16le', 'utf-16be', 'iso-8859-1', 'iso-8859-15',
+    'windows-1250', 'windows-1251', 'windows-1252', 'koi8-r'
+  ];
+
   uploadStatus = 0;
+  uploadProgress = 0;
+  uploadError = 'Unable to upload file';
   editPatternModal = false;
 
   fileContent: string[] = [];
@@ -52,6 +110,18 @@ export class UploadComponent implements OnInit {
   previewContent: string[] = [];
   previewTable: string[][] = [];
   previewTableMaxCols = 0;
+  previewHeaders: string[] = [];
+  previewError = '';
+  skippedLines: SkippedLine[] = [];
+  detection: Detection | null = null;
+  detectError = '';
+  private detected: Detection | null = null;
+  tables: Table[] = [];
+  private previewRequest?: Subscription;
+
+  fieldTypes = ['email', 'username', 'password', 'hash', 'ip', 'phone'];
+  columnNames: string[] = [];
+  columnTypes: string[] = [];
 
   constructor(
     private apiService: ApiService
@@ -59,8 +129,20 @@ export class UploadComponent implements OnInit {
 
   ngOnInit(): void {
     this.patternForm.setValue({
+      format: 'text',
       separator: ':',
-      commentChar: '#'
+      regex: false,
+      commentChar: '#',
+      quote: '"',
+      escape: '"',
+      lineRegex: '',
+      table: '',
+      encoding: '',
+      maxLine: 1024,
+      longLines: 'skip',
+      header: false,
+      priority: 0,
+      workers: 0
     });
     this.patternString();
 
@@ -71,38 +153,57 @@ export class UploadComponent implements OnInit {
 
     this.onPatternChange();
     this.onCommentChange();
+    this.onEncodingChange();
   }
 
   public onSubmit(): void {
-    const formData = new FormData();
-    formData.append('file', this.uploadForm.get('file')?.value);
-    formData.append('pattern', this.uploadForm.get('pattern')?.value);
-    formData.append('columns', this.uploadForm.get('columns')?.value);
+    const file: File = this.uploadForm.get('file')?.value;
+    const metadata: { [key: string]: string } = {
+      ...this.parserSettings(),
+      filename: file.name,
+      columns: this.selectedColumns(),
+      priority: String(this.patternForm.get('priority')?.value),
+      workers: String(this.patternForm.get('workers')?.value)
+    };
     this.uploadStatus = 1;
+    this.uploadProgress = 0;
 
-    this.apiService.upload(formData)
+    this.apiService.resumableUpload(file, metadata)
       .subscribe(
-        (_) => {
-          this.uploadStatus = 2;
+        (progress: number) => {
+          this.uploadProgress = progress;
         },
-        _ => this.uploadStatus = -1
+        (err) => {
+          this.uploadError = typeof err.error === 'string' && err.error.trim()
+            ? err.error.trim()
+            : 'Unable to upload file';
+          this.uploadStatus = -1;
+        },
+        () => {
+          this.uploadStatus = 2;
+        }
       );
   }
 
   public onFileSelect(event: any): void {
+    this.detected = null;
+    this.detection = null;
+    this.detectError = '';
     this.uploadForm.controls.file.setValue(null);
     this.uploadForm.controls.columns.setValue([]);
     this.previewContent = ['Loading file...'];
 
     if (event.target.files.length > 0) {
       const file = event.target.files[0];
-      if (!file.type.startsWith('text/')) {
-        this.previewContent = ['Invalid file type.'];
-        this.uploadForm.controls.file.setValue(null);
+      this.uploadForm.controls.file.setValue(file);
+      if (/\.(tgz|zip|tar(\.(gz|bz2|xz|zst))?)$/i.test(file.name)) {
+        this.previewContent = ['Archive file, every member will be imported as a separate file.'];
         return;
       }
+      if (/\.(gz|bz2|xz|zst)$/i.test(file.name)) {
+        this.previewContent = ['Compressed file, it will be decompressed during upload.'];
+      }
 
-      this.uploadForm.controls.file.setValue(file);
       this.readFile();
     }
   }
@@ -110,11 +211,100 @@ export class UploadComponent implements OnInit {
   public patternString(): void {
     const separator = this.patternForm.get('separator')?.value;
     const commentChar = this.patternForm.get('commentChar')?.value;
+    const escape = (v: string) => v.replace(/[{}]/g, '\\$&');
 
-    const value = `{${separator}}{${commentChar}}`;
+    const value = this.isRegex()
+      ? `r{${separator}}{${escape(commentChar)}}`
+      : `{${escape(separator)}}{${escape(commentChar)}}`;
     this.uploadForm.controls.pattern.setValue(value);
   }
 
+  /*
+  Ask the server to propose parser settings for the selected file
+  */
+  public detectFormat(): void {
+    const file: File = this.uploadForm.get('file')?.value;
+    if (file == null) {
+      return;
+    }
+    const formData = new FormData();
+    formData.append('encoding', this.patternForm.get('encoding')?.value);
+    formData.append('size', String(file.size));
+    formData.append('file', file.slice(0, PREVIEW_SIZE));
+
+    this.detectError = '';
+    this.apiService.detect(formData)
+      .subscribe(
+        (detection: any) => this.detection = detection,
+        (err) => {
+          this.detection = null;
+          this.detectError = typeof err.error === 'string' && err.error.trim()
+            ? err.error.trim()
+            : 'Unable to detect the file format.';
+        }
+      );
+  }
+
+  public editPattern(): void {
+    /* Settings edited by hand replace the accepted ones */
+    this.detected = null;
+    this.editPatternModal = true;
+  }
+
+  /*
+  Apply the proposed settings, columns are selected every time the
+  preview of the new settings is loaded
+  */
+  public acceptDetection(): void {
+    const detection = this.detection;
+    if (detection == null) {
+      return;
+    }
+    const config = detection.config;
+    const groups = /^\{((?:\\.|[^}])*)\}\{((?:\\.|[^}])*)\}$/.exec(config.pattern || '');
+    const unescape = (v: string) => v.replace(/\\([{}])/g, '$1');
+
+    this.patternForm.patchValue({
+      format: config.format,
+      separator: groups ? unescape(groups[1]) : this.patternForm.get('separator')?.value,
+      commentChar: groups ? unescape(groups[2]) : this.patternForm.get('commentChar')?.value,
+      regex: false,
+      quote: config.quote || '"',
+      table: config.table || '',
+      header: config.header === true,
+      encoding: config.encoding || ''
+    });
+    this.detected = detection;
+    this.detection = null;
+    this.patternString();
+  }
+
+  public isSQL(): boolean {
+    const format = this.patternForm.get('format')?.value;
+    return format === 'sql' || format === 'copy';
+  }
+
+  public isJSON(): boolean {
+    return this.patternForm.get('format')?.value === 'json';
+  }
+
+  public isHeader(): boolean {
+    const format = this.patternForm.get('format')?.value;
+    return this.patternForm.get('header')?.value === true && (format === 'text' || format === 'csv');
+  }
+
+  public isLineRegex(): boolean {
+    return this.patternForm.get('format')?.value === 'regex';
+  }
+
+  public isRegex(): boolean {
+    return this.patternForm.get('regex')?.value === true && !this.isCSV();
+  }
+
+  public isCSV(): boolean {
+    return this.patternForm.get('format')?.value === 'csv';
+  }
+
   public loadingModal(): boolean {
     return this.uploadStatus !== 0;
   }
@@ -153,9 +343,13 @@ export class UploadComponent implements OnInit {
     if (file == null) {
       return;
     }
+    if (/\.(gz|bz2|xz|zst)$/i.test(file.name)) {
+      this.processPreview();
+      return;
+    }
 
     const reader: FileReader = new FileReader();
-    reader.readAsText(file.slice(0, 8192));
+    reader.readAsText(file.slice(0, 8192), this.patternForm.get('encoding')?.value || undefined);
     reader.onloadend = () => {
       this.fileContentRaw = reader.result;
       this.processPreview();
@@ -172,37 +366,229 @@ export class UploadComponent implements OnInit {
     if (this.uploadForm.get('file')?.value == null) {
       return;
     }
-    const separator = this.patternForm.get('separator')?.value;
-
     this.previewTable = [];
     this.previewTableMaxCols = 0;
-    this.previewContent.forEach(content => {
-      const values = content.replace(' ', '').split(separator);
-      if (values.length > this.previewTableMaxCols) {
-        this.previewTableMaxCols = values.length;
+    this.previewHeaders = [];
+    this.previewError = '';
+    this.skippedLines = [];
+    this.columnNames = [];
+    this.columnTypes = [];
+    this.previewRequest?.unsubscribe();
+    if (this.isJSON()) {
+      this.parseJSONPreview();
+      return;
+    }
+    if (this.isSQL()) {
+      this.parseSQLPreview();
+      return;
+    }
+
+    this.parseServerPreview();
+  }
+
+  /*
+  Entries of text, CSV and regex files are parsed by the server with
+  the upload settings, along with the lines that would be skipped
+  */
+  private parseServerPreview(): void {
+    const file: File = this.uploadForm.get('file')?.value;
+    const settings = this.parserSettings();
+    settings.columns = this.isLineRegex() || this.isHeader()
+      ? ''
+      : Array.from({ length: PREVIEW_COLUMNS }, (_, i) => i).join(',');
+
+    const formData = new FormData();
+    Object.keys(settings).forEach(key => formData.append(key, settings[key]));
+    formData.append('size', String(file.size));
+    formData.append('file', file.slice(0, PREVIEW_SIZE));
+
+    this.previewRequest = this.apiService.preview(formData)
+      .subscribe(
+        (preview: any) => this.showPreview(preview),
+        (err) => {
+          this.previewError = typeof err.error === 'string' && err.error.trim()
+            ? err.error.trim()
+            : 'Unable to parse the input file.';
+          this.uploadForm.get('columns')?.setValue([]);
+        }
+      );
+  }
+
+  private showPreview(preview: Preview): void {
+    const entries = preview.entries || [];
+    this.skippedLines = preview.skipped_lines || [];
+    this.previewError = preview.error || '';
+
+    if (this.isLineRegex() || this.isHeader()) {
+      entries.forEach(entry => Object.keys(entry.fields).forEach(name => {
+        if (this.previewHeaders.indexOf(name) === -1) {
+          this.previewHeaders.push(name);
+        }
+      }));
+      this.previewTableMaxCols = this.previewHeaders.length;
+      this.previewTable = entries.map(entry => this.previewHeaders.map(name => entry.fields[name] || 'N/A'));
+
+      /* Every named group or header column is indexed by default */
+      this.uploadForm.get('columns')?.setValue(this.previewHeaders.map((_, i) => i));
+      this.applyDetectedColumns();
+      return;
+    }
+
+    /* Positional fields are named by column index */
+    entries.forEach(entry => Object.keys(entry.fields).forEach(name => {
+      this.previewTableMaxCols = Math.max(this.previewTableMaxCols, Number(name) + 1);
+    }));
+    this.previewTable = entries.map(entry => {
+      const row: string[] = [];
+      for (let i = 0; i < this.previewTableMaxCols; i++) {
+        row.push(entry.fields[String(i)] || 'N/A');
       }
+      return row;
     });
+    this.uploadForm.get('columns')?.setValue([]);
+    this.applyDetectedColumns();
+  }
 
-    this.previewContent.forEach(content => {
-      const tableRow: string[] = [];
-      for (let i = 0; i < this.previewTableMaxCols; i++) {
-        tableRow[i] = 'N/A';
+  private applyDetectedColumns(): void {
+    const detected = this.detected;
+    if (detected == null) {
+      return;
+    }
+
+    const named = this.isJSON() || this.isSQL() || this.isHeader();
+    const selected: number[] = [];
+    detected.columns.forEach(column => {
+      const i = named ? this.previewHeaders.indexOf(column.selector) : Number(column.selector);
+      if (i < 0 || i >= this.previewTableMaxCols) {
+        return;
       }
+      selected.push(i);
+      this.columnNames[i] = column.name !== column.selector ? column.name : '';
+      this.columnTypes[i] = column.type;
+    });
+    this.uploadForm.get('columns')?.setValue(selected);
+  }
 
-      const values = content.replace(' ', '').split(separator);
-      for (let j = 0; j < values.length; j++) {
-        if (values[j].length > 1) {
-          tableRow[j] = values[j];
-        }
+  private parseSQLPreview(): void {
+    const file = this.uploadForm.get('file')?.value;
+    const formData = new FormData();
+    formData.append('format', this.patternForm.get('format')?.value);
+    formData.append('file', file.slice(0, 1024 * 1024));
+
+    this.apiService.schema(formData)
+      .subscribe(
+        (tables: any) => {
+          this.tables = tables || [];
+          let table = this.tables.find(t => t.name === this.patternForm.get('table')?.value);
+          if (!table && this.tables.length) {
+            table = this.tables[0];
+            this.patternForm.get('table')?.setValue(table.name);
+          }
+          if (!table) {
+            return;
+          }
+
+          this.previewHeaders = table.columns.length
+            ? table.columns
+            : (table.rows[0] || []).map((_, i) => String(i));
+          this.previewTableMaxCols = this.previewHeaders.length;
+          this.previewTable = table.rows;
+          this.uploadForm.get('columns')?.setValue(this.previewHeaders.map((_, i) => i));
+          this.applyDetectedColumns();
+        },
+        _ => this.tables = []
+      );
+  }
+
+  private parseJSONPreview(): void {
+    const records: Map<string, string>[] = [];
+    this.previewContent.forEach(content => {
+      /* Array elements are expected one per line in the preview */
+      const line = content.trim().replace(/^\[/, '').replace(/[,\]]$/, '');
+      try {
+        const record = new Map<string, string>();
+        this.flatten('', JSON.parse(line), record);
+        records.push(record);
+      } catch {
+        return;
       }
+    });
 
-      this.previewTable.push(tableRow);
+    records.forEach(record => record.forEach((_, key) => {
+      if (this.previewHeaders.indexOf(key) === -1) {
+        this.previewHeaders.push(key);
+      }
+    }));
+    this.previewTableMaxCols = this.previewHeaders.length;
+    records.forEach(record => {
+      this.previewTable.push(this.previewHeaders.map(key => record.get(key) || 'N/A'));
     });
 
-    this.uploadForm.get('columns')?.setValue([]);
+    /* Every JSON path is indexed by default */
+    this.uploadForm.get('columns')?.setValue(this.previewHeaders.map((_, i) => i));
+    this.applyDetectedColumns();
+  }
+
+  private flatten(prefix: string, value: any, record: Map<string, string>): void {
+    if (value === null || value === undefined) {
+      return;
+    }
+    if (typeof value === 'object') {
+      Object.keys(value).forEach(key => {
+        this.flatten(prefix ? `${prefix}.${key}` : key, value[key], record);
+      });
+      return;
+    }
+    record.set(prefix || 'value', String(value));
+  }
+
+  private selectedColumns(): string {
+    const selected: number[] = this.uploadForm.get('columns')?.value;
+    const named = this.isLineRegex() || this.isJSON() || this.isSQL() || this.isHeader();
+
+    /* Column spec: selector[:name[:type]] */
+    return selected.map(i => {
+      const selector = named ? this.previewHeaders[i] : String(i);
+      const name = (this.columnNames[i] || '').trim();
+      const type = this.columnTypes[i] || '';
+      if (type) {
+        return `${selector}:${name}:${type}`;
+      }
+      return name ? `${selector}:${name}` : selector;
+    }).join(',');
+  }
+
+  private parserSettings(): { [key: string]: string } {
+    const settings: { [key: string]: string } = {
+      pattern: this.uploadForm.get('pattern')?.value,
+      format: this.patternForm.get('format')?.value,
+      encoding: this.patternForm.get('encoding')?.value,
+      max_line: String(this.patternForm.get('maxLine')?.value * 1024),
+      long_lines: this.patternForm.get('longLines')?.value
+    };
+    if (this.isLineRegex()) {
+      settings.regex = this.patternForm.get('lineRegex')?.value;
+    }
+    if (this.isSQL()) {
+      settings.table = this.patternForm.get('table')?.value;
+    }
+    if (this.patternForm.get('format')?.value === 'text' || this.isCSV()) {
+      settings.header = String(this.isHeader());
+    }
+    if (this.isCSV()) {
+      settings.quote = this.patternForm.get('quote')?.value;
+      settings.escape = this.patternForm.get('escape')?.value;
+    }
+
+    return settings;
   }
 
   private processPreview(): void {
+    /* Compressed files are only parsed by the server */
+    if (this.fileContentRaw == null) {
+      this.parsePreview();
+      return;
+    }
     this.fileContent = this.fileContentRaw.split(/[\r\n]+/g);
 
     this.previewContent = [];
@@ -213,7 +599,7 @@ export class UploadComponent implements OnInit {
       }
 
       const commentChar = this.patternForm.get('commentChar')?.value;
-      if (content.replace(' ', '').charAt(0) === commentChar) {
+      if (commentChar && content.replace(/ /g, '').startsWith(commentChar)) {
         continue;
       }
       this.previewContent.push(content);
@@ -229,6 +615,13 @@ export class UploadComponent implements OnInit {
       });
   }
 
+  private onEncodingChange(): void {
+    this.patternForm.get('encoding')?.valueChanges
+      .subscribe(_ => {
+        this.readFile();
+      });
+  }
+
   private onCommentChange(): void {
     this.patternForm.get('commentChar')?.valueChanges
       .subscribe(_ => {
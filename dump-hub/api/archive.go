@@ -0,0 +1,148 @@
+package api
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/x0e1f/dump-hub/common"
+	"github.com/x0e1f/dump-hub/elastic"
+	"github.com/x0e1f/dump-hub/stream"
+)
+
+/*
+memberConfigs :: Parse per member parser settings (JSON object
+member name -> parser settings), every setting is validated
+*/
+func memberConfigs(value string) (map[string]*common.ParserConfig, error) {
+	configs := map[string]*common.ParserConfig{}
+	if len(value) < 1 {
+		return configs, nil
+	}
+
+	err := json.Unmarshal([]byte(value), &configs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid members value: %s", err)
+	}
+	for name, config := range configs {
+		_, err := newParser(config)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+	}
+
+	return configs, nil
+}
+
+/*
+queueArchive :: Store extraction job of an archive and extract it in
+background, the stored job resumes the extraction after a restart
+*/
+func queueArchive(e *elastic.Client, settings *uploadSettings, fn string, fp string, checkSum string) *common.Job {
+	j := &common.Job{
+		ID:       uuid.New().String(),
+		Type:     common.JobExtract,
+		Date:     time.Now().Format("2006-01-02 15:04:05"),
+		Checksum: checkSum,
+		Filename: fn,
+		Path:     fp,
+		Source:   settings.source,
+		Config:   settings.config,
+		Priority: settings.priority,
+		Workers:  settings.workers,
+		Members:  settings.members,
+		Tags:     settings.tags,
+	}
+	err := e.SaveJob(j)
+	if err != nil {
+		log.Printf("(ERROR) (%s) %s", fn, err)
+	}
+	go processArchive(e, j)
+
+	return j
+}
+
+/*
+processArchive :: Extract archive on tmp and queue every member as its
+own file, members are stored as jobs before the archive is released.
+Members queued before a restart are skipped as duplicates
+*/
+func processArchive(e *elastic.Client, j *common.Job) {
+	log.Printf("Extracting %s", j.Filename)
+	settings := jobSettings(j, common.SourceUpload)
+
+	err := stream.Extract(j.Path, "/tmp", func(member *stream.Member) error {
+		memberSettings := *settings
+		if config, ok := settings.members[member.Name]; ok {
+			memberSettings.config = config
+		}
+
+		err := queueMember(e, &memberSettings, path.Join(j.Filename, member.Name), member)
+		if err != nil {
+			log.Printf("(ERROR) (%s) %s", member.Name, err)
+			os.Remove(member.Path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Printf("(ERROR) (%s) %s", j.Filename, err)
+	}
+
+	dErr := e.DeleteJob(j.ID)
+	if dErr != nil {
+		log.Println(dErr)
+	}
+	releaseFile(j.Source, j.Path, err == nil)
+}
+
+/*
+queueMember :: Create history document and queue import job of an
+archive member
+*/
+func queueMember(e *elastic.Client, settings *uploadSettings, fn string, member *stream.Member) error {
+	_, err := newParser(settings.config)
+	if err != nil {
+		return err
+	}
+
+	/* Check if member already exist */
+	fileExist, err := e.IsAlreadyUploaded(member.Checksum)
+	if err != nil {
+		return err
+	}
+	if fileExist {
+		return fmt.Errorf("file already exist: %s", member.Checksum)
+	}
+
+	_, err = queueHistory(e, settings, fn, member.Path, member.Checksum)
+
+	return err
+}
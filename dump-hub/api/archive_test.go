@@ -0,0 +1,133 @@
+package api
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/x0e1f/dump-hub/common"
+)
+
+/*
+copyFixture :: Copy a testdata file to a temporary dir, returns its path
+*/
+func copyFixture(t *testing.T, name string) string {
+	t.Helper()
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp := filepath.Join(t.TempDir(), name)
+	err = ioutil.WriteFile(fp, data, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fp
+}
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestProcessArchive(t *testing.T) {
+	node, e := newTestNode(t)
+	imports = newScheduler(e, 2, 1)
+	client := events.subscribe()
+	defer events.unsubscribe(client)
+
+	/* Members of the nested archive are named after its path */
+	members := map[string]struct {
+		checksum string
+		entries  int
+	}{
+		"nested.zip/notes.txt":              {sha256Hex("u4@x.y:p4\n"), 1},
+		"nested.zip/inner.tar.gz/users.txt": {sha256Hex("u3@x.y:p3\n"), 0},
+		"nested.zip/dump.txt.gz":            {"3dc2aa58029a8a9331d8db72f2d1ded2f15488bb9ab31746b4761e6639365727", 2},
+	}
+	settings := &uploadSettings{
+		config: &common.ParserConfig{Pattern: "{:}{#}", Columns: "0:email:email,1"},
+		members: map[string]*common.ParserConfig{
+			"inner.tar.gz/users.txt": {Pattern: "{;}{#}", Columns: "1"},
+		},
+		source: common.SourceUpload,
+	}
+
+	fp := copyFixture(t, "nested.zip")
+	j := queueArchive(e, settings, "nested.zip", fp, "archive")
+	finished := map[string]bool{}
+	timeout := time.After(10 * time.Second)
+	for len(finished) < len(members) {
+		select {
+		case event := <-client:
+			if event.Type == common.EventCompleted || event.Type == common.EventFailed {
+				finished[event.Filename] = true
+			}
+		case <-timeout:
+			t.Fatalf("imports finished: %v", finished)
+		}
+	}
+
+	for name, member := range members {
+		history := node.doc("dump-hub-history", member.checksum)
+		if !finished[name] || history == nil || history["filename"] != name {
+			t.Errorf("%s: history %v", name, history)
+			continue
+		}
+		if n := node.entries(member.checksum); n != member.entries {
+			t.Errorf("%s: got %d entries, want %d", name, n, member.entries)
+		}
+	}
+
+	/* The extraction job and the archive are released after extraction */
+	for i := 0; node.doc("dump-hub-jobs", j.ID) != nil; i++ {
+		if i > 1000 {
+			t.Fatal("extraction job not deleted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := os.Stat(fp); !os.IsNotExist(err) {
+		t.Errorf("archive not removed: %v", err)
+	}
+
+	/* Members imported before are skipped */
+	histories := node.count("dump-hub-history")
+	j = queueArchive(e, settings, "nested.zip", copyFixture(t, "nested.zip"), "archive")
+	for i := 0; node.doc("dump-hub-jobs", j.ID) != nil; i++ {
+		if i > 1000 {
+			t.Fatal("extraction job not deleted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := node.count("dump-hub-history"); n != histories {
+		t.Errorf("got %d history documents, want %d", n, histories)
+	}
+}
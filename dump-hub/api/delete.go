@@ -27,7 +27,10 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/x0e1f/dump-hub/common"
 	"github.com/x0e1f/dump-hub/elastic"
 )
 
@@ -36,9 +39,10 @@ type deleteReq struct {
 }
 
 /*
-delete :: Delete entry from elasticsearch
+deleteHistory :: Delete entry from elasticsearch, the import of a
+file that is still running is cancelled and rolled back
 */
-func delete(eClient *elastic.Client) http.HandlerFunc {
+func deleteHistory(eClient *elastic.Client) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var deleteReq deleteReq
 
@@ -49,9 +53,46 @@ func delete(eClient *elastic.Client) http.HandlerFunc {
 			return
 		}
 
-		checkSum := deleteReq.Checksum
-		go eClient.DeleteEntries(checkSum)
+		/* Running imports are cancelled and rolled back */
+		if j := checksumJob(deleteReq.Checksum); j != nil {
+			j.stop(true)
+			imports.cancel(j)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		/* Store job to resume the deletion after a restart */
+		j := &common.Job{
+			ID:       uuid.New().String(),
+			Type:     common.JobDelete,
+			Date:     time.Now().Format("2006-01-02 15:04:05"),
+			Checksum: deleteReq.Checksum,
+		}
+		err = eClient.SaveJob(j)
+		if err != nil {
+			log.Println(err)
+		}
+		go deleteEntries(eClient, j)
 
 		w.WriteHeader(http.StatusOK)
 	}
 }
+
+/*
+deleteEntries :: Delete entries of a file, notify clients and
+remove the finished job. The history element of a failed deletion
+is kept with the failed status
+*/
+func deleteEntries(eClient *elastic.Client, j *common.Job) {
+	events.publish(common.EventDeleting, j.Checksum, "", common.StatusDeleting, nil)
+	err := eClient.DeleteEntries(j.Checksum)
+	if jErr := eClient.DeleteJob(j.ID); jErr != nil {
+		log.Println(jErr)
+	}
+	if err != nil {
+		log.Printf("(ERROR) (%s) Unable to delete entries: %s", j.Checksum, err)
+		events.publish(common.EventFailed, j.Checksum, "", common.StatusFailed, nil)
+		return
+	}
+	events.publish(common.EventDeleted, j.Checksum, "", common.StatusDeleting, nil)
+}
@@ -0,0 +1,64 @@
+package api
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/x0e1f/dump-hub/common"
+)
+
+func TestDeleteRunningImport(t *testing.T) {
+	node, e := newTestNode(t)
+	imports = newScheduler(e, 1, 1)
+	client := events.subscribe()
+	defer events.unsubscribe(client)
+
+	arrived, release := holdBulk(node)
+	j := importDump(t, e, writeDump(t, 5000), "running")
+	waitArrived(t, arrived)
+
+	w := httptest.NewRecorder()
+	deleteHistory(e)(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(`{"checksum":"running"}`))))
+	if w.Code != http.StatusOK {
+		t.Fatalf("delete: got %d", w.Code)
+	}
+	close(release)
+
+	/* The import is cancelled and its entries are rolled back */
+	waitEvent(t, client, "running", common.EventDeleted)
+	waitUnregistered(t, j)
+	if count := node.entries("running"); count > 0 {
+		t.Errorf("%d entries left", count)
+	}
+	if node.doc("dump-hub-history", "running") != nil {
+		t.Error("history not deleted")
+	}
+	if count := node.count("dump-hub-jobs"); count > 0 {
+		t.Errorf("%d stored jobs left", count)
+	}
+}
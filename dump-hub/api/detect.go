@@ -0,0 +1,85 @@
+package api
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/x0e1f/dump-hub/parser"
+	"github.com/x0e1f/dump-hub/stream"
+)
+
+/*
+detect :: Propose parser settings for a file sample (POST), the sample
+is sent like the preview one. Encoding is detected unless set
+*/
+func detect() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.ParseMultipartForm(maxSampleSize)
+
+		sample, _, partial, err := previewSample(r)
+		if os.IsNotExist(err) {
+			http.Error(w, "upload not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("(ERROR) (%s) %s", r.URL, err)
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+
+		encoding := r.FormValue("encoding")
+		if len(encoding) > 0 && !stream.IsEncoding(encoding) {
+			http.Error(w, "unsupported encoding: "+encoding, http.StatusBadRequest)
+			return
+		}
+		text, enc, _, _, err := sampleText(sample, partial, encoding)
+		if err != nil {
+			log.Printf("(ERROR) (%s) %s", r.URL, err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		detection, err := parser.Detect(text)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		detection.Config.Encoding = enc
+
+		response, err := json.Marshal(detection)
+		if err != nil {
+			http.Error(w, "", http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response)
+	}
+}
@@ -36,28 +36,41 @@ import (
 Engine :: Core API Engine
 */
 type Engine struct {
-	host    string
-	port    int
-	baseAPI string
-	router  *mux.Router
-	eClient *elastic.Client
+	host      string
+	port      int
+	baseAPI   string
+	router    *mux.Router
+	eClient   *elastic.Client
+	importDir string
 }
 
 const pageSize = 20
 
 /*
-New :: Create the Api Engine object
+New :: Create the Api Engine object, at most maxJobs imports run
+at the same time with workers uploader routines by default. Files
+placed in watchDir are imported, files in importDir can be imported
+in place from the API (disabled if empty)
 */
-func New(host string, port int, baseAPI string, eClient *elastic.Client) *Engine {
+func New(host string, port int, baseAPI string, eClient *elastic.Client, maxJobs int, workers int, watchDir string, importDir string) *Engine {
 	log.Println("Initializing engine...")
 	engine := &Engine{
-		host:    host,
-		port:    port,
-		baseAPI: baseAPI,
-		eClient: eClient,
+		host:      host,
+		port:      port,
+		baseAPI:   baseAPI,
+		eClient:   eClient,
+		importDir: importDir,
 	}
 	engine.defineRoutes()
 
+	/* Jobs interrupted by a restart are queued again */
+	imports = newScheduler(eClient, maxJobs, workers)
+	resumeJobs(eClient)
+	go expireUploads()
+	if len(watchDir) > 0 {
+		go watchFolder(eClient, watchDir)
+	}
+
 	return engine
 }
 
@@ -0,0 +1,172 @@
+package api
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/x0e1f/dump-hub/common"
+)
+
+/* Events buffered for every client, slower clients lose events */
+const eventBuffer = 64
+
+/* Interval of progress events and keep alive comments */
+const (
+	progressInterval  = time.Second
+	keepAliveInterval = 15 * time.Second
+)
+
+/*
+eventBroker :: Fan out job events to connected clients
+*/
+type eventBroker struct {
+	mutex   sync.Mutex
+	clients map[chan *common.Event]struct{}
+}
+
+/*
+events :: Job events broker
+*/
+var events = &eventBroker{
+	clients: map[chan *common.Event]struct{}{},
+}
+
+/*
+subscribe :: Register a new client
+*/
+func (b *eventBroker) subscribe() chan *common.Event {
+	client := make(chan *common.Event, eventBuffer)
+
+	b.mutex.Lock()
+	b.clients[client] = struct{}{}
+	b.mutex.Unlock()
+
+	return client
+}
+
+/*
+unsubscribe :: Remove a client
+*/
+func (b *eventBroker) unsubscribe(client chan *common.Event) {
+	b.mutex.Lock()
+	delete(b.clients, client)
+	b.mutex.Unlock()
+}
+
+/*
+publish :: Send event to every client without blocking
+*/
+func (b *eventBroker) publish(eventType string, cs string, fn string, status int, progress *common.Progress) {
+	event := &common.Event{
+		Type:     eventType,
+		Checksum: cs,
+		Filename: fn,
+		Status:   status,
+		Progress: progress,
+		Time:     time.Now().Format("2006-01-02 15:04:05"),
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for client := range b.clients {
+		select {
+		case client <- event:
+		default:
+		}
+	}
+}
+
+/*
+publishProgress :: Publish progress events of a job until done is closed
+*/
+func publishProgress(j *job, done <-chan struct{}) {
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			events.publish(
+				common.EventProgress,
+				j.Checksum,
+				j.Filename,
+				common.StatusProcessing,
+				j.status(),
+			)
+		}
+	}
+}
+
+/*
+streamEvents :: Push job events to the client (Server-Sent Events)
+*/
+func streamEvents() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming not supported", http.StatusInternalServerError)
+			return
+		}
+
+		/* Clients get every event published once the response starts */
+		client := events.subscribe()
+		defer events.unsubscribe(client)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		/* Disable nginx proxy buffering */
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(keepAliveInterval)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+			case event := <-client:
+				data, err := json.Marshal(event)
+				if err != nil {
+					log.Println(err)
+					continue
+				}
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+			}
+			flusher.Flush()
+		}
+	}
+}
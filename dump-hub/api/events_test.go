@@ -0,0 +1,117 @@
+package api
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/x0e1f/dump-hub/common"
+)
+
+/*
+readEvents :: Parse Server-Sent Events of a response
+*/
+func readEvents(t *testing.T, resp *http.Response) chan *common.Event {
+	received := make(chan *common.Event, eventBuffer)
+	go func() {
+		defer close(received)
+		eventType := ""
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				eventType = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				event := &common.Event{}
+				err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), event)
+				if err != nil || event.Type != eventType {
+					t.Errorf("invalid event %q: %v", line, err)
+					continue
+				}
+				received <- event
+			}
+		}
+	}()
+
+	return received
+}
+
+func TestStreamEvents(t *testing.T) {
+	node, e := newTestNode(t)
+	imports = newScheduler(e, 1, 1)
+	server := newTestEngine(t, e)
+
+	resp, err := http.Get(server.URL + "/api/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	received := readEvents(t, resp)
+
+	/* Bulk requests wait for a progress event */
+	_, release := holdBulk(node)
+	defer func() {
+		select {
+		case <-release:
+		default:
+			close(release)
+		}
+	}()
+	importDump(t, e, writeDump(t, 100), "events")
+
+	want := []string{common.EventQueued, common.EventStarted, common.EventProgress, common.EventCompleted}
+	timeout := time.After(10 * time.Second)
+	for len(want) > 0 {
+		select {
+		case event, ok := <-received:
+			if !ok {
+				t.Fatal("event stream closed")
+			}
+			if event.Checksum != "events" || event.Type != want[0] {
+				continue
+			}
+			if event.Type == common.EventProgress {
+				if event.Progress == nil || event.Progress.Size < 1 {
+					t.Errorf("progress event without progress: %+v", event)
+				}
+				close(release)
+			}
+			if event.Type == common.EventCompleted && event.Status != common.StatusComplete {
+				t.Errorf("completed event status: got %d", event.Status)
+			}
+			want = want[1:]
+		case <-timeout:
+			t.Fatalf("events not received: %v", want)
+		}
+	}
+}
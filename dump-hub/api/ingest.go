@@ -0,0 +1,217 @@
+package api
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"io"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/x0e1f/dump-hub/common"
+	"github.com/x0e1f/dump-hub/elastic"
+	"github.com/x0e1f/dump-hub/parser"
+)
+
+/*
+bulkFunc :: Index a chunk of documents
+*/
+type bulkFunc func([]*elastic.Document) (*elastic.BulkResult, error)
+
+/*
+chunk :: Consecutive entries of a file starting from entry number
+first, report counts the lines read and the entries indexed
+*/
+type chunk struct {
+	first   int
+	entries []*common.Entry
+	report  *importReport
+}
+
+/*
+newChunk :: Create empty chunk
+*/
+func newChunk(first int) *chunk {
+	return &chunk{
+		first:  first,
+		report: newImportReport(),
+	}
+}
+
+/*
+checkpoint :: Number of leading entries of a file indexed (or
+counted as failed) and the report of their lines. Chunks are
+indexed concurrently, a chunk is added once the previous ones are
+*/
+type checkpoint struct {
+	mutex  sync.Mutex
+	offset int
+	done   map[int]*chunk
+	report *importReport
+	save   func(int, *common.Report)
+}
+
+/*
+newCheckpoint :: Create checkpoint of an import resumed from offset,
+save is called when the checkpoint moves forward and may be nil
+*/
+func newCheckpoint(offset int, report *common.Report, save func(int, *common.Report)) *checkpoint {
+	return &checkpoint{
+		offset: offset,
+		done:   map[int]*chunk{},
+		report: restoreReport(report),
+		save:   save,
+	}
+}
+
+/*
+complete :: Add an indexed chunk to the checkpoint
+*/
+func (cp *checkpoint) complete(c *chunk) {
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
+
+	cp.done[c.first] = c
+	moved := false
+	for {
+		next, ok := cp.done[cp.offset]
+		if !ok {
+			break
+		}
+		delete(cp.done, cp.offset)
+		cp.report.merge(&next.report.report)
+		cp.offset += len(next.entries)
+		moved = true
+	}
+
+	if moved && cp.save != nil {
+		cp.save(cp.offset, &cp.report.report)
+	}
+}
+
+/*
+ingest :: Parse entries from reader and index them in chunks from a
+pool of uploaders. Returns when every parsed entry has been indexed
+or counted as failed in the report. Entries and lines before the
+checkpoint offset were indexed by a previous run and are skipped
+*/
+func ingest(r io.Reader, p *parser.Parser, fn string, cs string, workers int, bulk bulkFunc, report *importReport, cp *checkpoint) error {
+	var wg sync.WaitGroup
+	chunkChan := make(chan *chunk, workers)
+	start := time.Now()
+
+	/* Start uploader routines */
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go uploader(i, &wg, bulk, report, cp, chunkChan)
+	}
+
+	/* Parse file entries, chunks are limited by documents number */
+	seen := 0
+	c := newChunk(cp.offset)
+	err := p.Parse(r, fn, cs, func(entry *common.Entry) {
+		seen++
+		if seen <= cp.offset {
+			return
+		}
+		report.parsed()
+		c.report.parsed()
+		c.entries = append(c.entries, entry)
+		if len(c.entries) >= elastic.BulkActions {
+			chunkChan <- c
+			c = newChunk(seen)
+		}
+	}, func(line *parser.Skipped) {
+		if seen < cp.offset {
+			return
+		}
+		report.skipped(line)
+		c.report.skipped(line)
+	})
+
+	/* Uploaders drain the channel before returning */
+	chunkChan <- c
+	close(chunkChan)
+	wg.Wait()
+	report.finish(time.Since(start))
+
+	return err
+}
+
+/*
+uploader :: Upload chunks to elastic until the channel is closed,
+bulk requests are also limited by documents size
+*/
+func uploader(id int, wg *sync.WaitGroup, bulk bulkFunc, report *importReport, cp *checkpoint, chunkChan <-chan *chunk) {
+	defer wg.Done()
+
+	for c := range chunkChan {
+		docs := []*elastic.Document{}
+		size := 0
+		for i, entry := range c.entries {
+			doc, err := elastic.EncodeEntry(entry)
+			if err != nil {
+				log.Printf("(ERROR) (uploader %d) %s", id, err)
+				result := &elastic.BulkResult{
+					Failed: 1,
+					Errors: map[string]int{"encoding error": 1},
+				}
+				report.indexed(result, 0)
+				c.report.indexed(result, 0)
+				continue
+			}
+
+			/* Request size reached */
+			if len(docs) > 0 && size+len(doc) > elastic.BulkSize {
+				uploadDocs(id, bulk, report, c, docs, size)
+				docs, size = []*elastic.Document{}, 0
+			}
+			docs = append(docs, &elastic.Document{
+				ID:     entry.OriginID + "-" + strconv.Itoa(c.first+i+1),
+				Source: doc,
+			})
+			size += len(doc)
+		}
+
+		/* If there is still data, upload it */
+		if len(docs) > 0 {
+			uploadDocs(id, bulk, report, c, docs, size)
+		}
+		cp.complete(c)
+	}
+}
+
+/*
+uploadDocs :: Index documents of a chunk and count indexed and
+failed entries
+*/
+func uploadDocs(id int, bulk bulkFunc, report *importReport, c *chunk, docs []*elastic.Document, size int) {
+	result, err := bulk(docs)
+	if err != nil {
+		log.Printf("(ERROR) (uploader %d) %s", id, err)
+	}
+	report.indexed(result, int64(size))
+	c.report.indexed(result, int64(size))
+}
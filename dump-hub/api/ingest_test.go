@@ -0,0 +1,306 @@
+package api
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/x0e1f/dump-hub/common"
+	"github.com/x0e1f/dump-hub/elastic"
+	"github.com/x0e1f/dump-hub/parser"
+)
+
+/*
+testIndex :: In memory bulk indexer
+*/
+type testIndex struct {
+	mutex    sync.Mutex
+	entries  []*common.Entry
+	ids      map[string]bool
+	maxBytes int
+}
+
+func (i *testIndex) bulk(docs []*elastic.Document) (*elastic.BulkResult, error) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if i.ids == nil {
+		i.ids = map[string]bool{}
+	}
+	size := 0
+	for _, doc := range docs {
+		entry, err := elastic.DecodeEntry(doc.Source)
+		if err != nil {
+			return nil, err
+		}
+		i.entries = append(i.entries, entry)
+		i.ids[doc.ID] = true
+		size += len(doc.Source)
+	}
+	if size > i.maxBytes {
+		i.maxBytes = size
+	}
+
+	return &elastic.BulkResult{Indexed: len(docs)}, nil
+}
+
+func newTestParser(t *testing.T) *parser.Parser {
+	p, err := parser.New(&common.ParserConfig{
+		Pattern: "{:}{#}",
+		Columns: "0:email:email,1:password:password",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestIngestKnownFile(t *testing.T) {
+	file, err := os.Open("testdata/combolist.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	index := &testIndex{}
+	report := newImportReport()
+	err = ingest(file, newTestParser(t), "combolist.txt", "checksum", 4, index.bulk, report, newCheckpoint(0, nil, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(index.entries) != 12 {
+		t.Errorf("indexed documents: got %d, want 12", len(index.entries))
+	}
+	if report.report.Lines != 18 {
+		t.Errorf("lines: got %d, want 18", report.report.Lines)
+	}
+	if report.report.Parsed != 12 || report.report.Indexed != 12 || report.report.Failed != 0 {
+		t.Errorf("report: got %+v", report.report)
+	}
+
+	skipped := map[string]int{
+		parser.ReasonComment:  2,
+		parser.ReasonEmpty:    2,
+		parser.ReasonNoFields: 2,
+	}
+	for reason, count := range skipped {
+		if report.report.Skipped[reason] != count {
+			t.Errorf("skipped %q: got %d, want %d", reason, report.report.Skipped[reason], count)
+		}
+	}
+	if len(report.report.Rejected) != 2 || report.report.Rejected[0].Line != 9 {
+		t.Errorf("rejected samples: got %+v", report.report.Rejected)
+	}
+	if !report.rejected() {
+		t.Error("rejected lines not detected")
+	}
+
+	emails := map[string]bool{}
+	for _, entry := range index.entries {
+		emails[entry.Fields["email"]] = true
+		if entry.OriginID != "checksum" {
+			t.Errorf("origin id: got %q", entry.OriginID)
+		}
+	}
+	if len(emails) != 12 || len(index.ids) != 12 {
+		t.Errorf("unique documents: got %d emails and %d ids, want 12", len(emails), len(index.ids))
+	}
+}
+
+func TestIngestChunks(t *testing.T) {
+	/* Entries do not fill the last chunk of every uploader */
+	total := elastic.BulkActions*3 + 7
+	lines := make([]string, total)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("user%d@example.com:pass%d", i, i)
+	}
+
+	for _, workers := range []int{1, 4, 16} {
+		index := &testIndex{}
+		report := newImportReport()
+		reader := strings.NewReader(strings.Join(lines, "\n"))
+		err := ingest(reader, newTestParser(t), "chunks.txt", "checksum", workers, index.bulk, report, newCheckpoint(0, nil, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(index.entries) != total {
+			t.Errorf("%d workers: indexed %d documents, want %d", workers, len(index.entries), total)
+		}
+		if report.report.Indexed != total {
+			t.Errorf("%d workers: report indexed %d, want %d", workers, report.report.Indexed, total)
+		}
+		if report.rejected() {
+			t.Errorf("%d workers: unexpected rejected lines", workers)
+		}
+	}
+}
+
+func TestIngestBulkFailures(t *testing.T) {
+	total := elastic.BulkActions*2 + 500
+	lines := make([]string, total)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("user%d@example.com:pass%d", i, i)
+	}
+
+	/* Every other request fails, the others reject one document */
+	var mutex sync.Mutex
+	requests := 0
+	bulk := func(docs []*elastic.Document) (*elastic.BulkResult, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		requests++
+		if requests%2 == 0 {
+			return &elastic.BulkResult{
+				Failed: len(docs),
+				Errors: map[string]int{"request error": len(docs)},
+			}, errors.New("bulk request failed")
+		}
+		return &elastic.BulkResult{
+			Indexed: len(docs) - 1,
+			Failed:  1,
+			Errors:  map[string]int{"mapper_parsing_exception": 1},
+		}, nil
+	}
+
+	report := newImportReport()
+	reader := strings.NewReader(strings.Join(lines, "\n"))
+	err := ingest(reader, newTestParser(t), "failures.txt", "checksum", 3, bulk, report, newCheckpoint(0, nil, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.report.Indexed+report.report.Failed != total {
+		t.Errorf("indexed %d + failed %d, want %d", report.report.Indexed, report.report.Failed, total)
+	}
+	if report.report.Failed < 1 || !report.rejected() {
+		t.Errorf("bulk failures not reported: %+v", report.report)
+	}
+	errorsCount := 0
+	for _, count := range report.report.Errors {
+		errorsCount += count
+	}
+	if errorsCount != report.report.Failed {
+		t.Errorf("errors by type: got %d, want %d", errorsCount, report.report.Failed)
+	}
+}
+
+func TestIngestChunkSize(t *testing.T) {
+	/* About 50 documents fit in a bulk request */
+	total := 500
+	value := strings.Repeat("x", elastic.BulkSize/50)
+	lines := make([]string, total)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("user%d@example.com:%s", i, value)
+	}
+
+	index := &testIndex{}
+	report := newImportReport()
+	reader := strings.NewReader(strings.Join(lines, "\n"))
+	err := ingest(reader, newTestParser(t), "large.txt", "checksum", 2, index.bulk, report, newCheckpoint(0, nil, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(index.entries) != total {
+		t.Errorf("indexed %d documents, want %d", len(index.entries), total)
+	}
+	if index.maxBytes > elastic.BulkSize {
+		t.Errorf("bulk request of %d bytes, limit %d", index.maxBytes, elastic.BulkSize)
+	}
+	if report.report.Bytes < int64(total*len(value)) {
+		t.Errorf("bytes sent: got %d", report.report.Bytes)
+	}
+}
+
+func TestIngestResume(t *testing.T) {
+	/* Comments between entries are counted once across runs */
+	total := elastic.BulkActions*4 + 10
+	lines := []string{}
+	for i := 0; i < total; i++ {
+		if i%100 == 0 {
+			lines = append(lines, "# comment")
+		}
+		lines = append(lines, fmt.Sprintf("user%d@example.com:pass%d", i, i))
+	}
+	input := strings.Join(lines, "\n")
+
+	/* Complete run, a checkpoint is stored after the second chunk */
+	full := &testIndex{}
+	fullReport := newImportReport()
+	var stored *common.Job
+	cp := newCheckpoint(0, nil, func(offset int, r *common.Report) {
+		if offset < elastic.BulkActions*2 || stored != nil {
+			return
+		}
+		data, err := json.Marshal(&common.Job{Offset: offset, Report: r})
+		if err != nil {
+			t.Fatal(err)
+		}
+		stored = &common.Job{}
+		json.Unmarshal(data, stored)
+	})
+	err := ingest(strings.NewReader(input), newTestParser(t), "resume.txt", "checksum", 3, full.bulk, fullReport, cp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored == nil {
+		t.Fatal("checkpoint not stored")
+	}
+
+	/* Resumed run indexes the entries after the checkpoint */
+	resumed := &testIndex{}
+	report := restoreReport(stored.Report)
+	cp = newCheckpoint(stored.Offset, stored.Report, nil)
+	err = ingest(strings.NewReader(input), newTestParser(t), "resume.txt", "checksum", 3, resumed.bulk, report, cp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resumed.entries) != total-stored.Offset {
+		t.Errorf("resumed documents: got %d, want %d", len(resumed.entries), total-stored.Offset)
+	}
+	for id := range resumed.ids {
+		if !full.ids[id] {
+			t.Errorf("unexpected document id %q", id)
+		}
+	}
+	if report.report.Lines != fullReport.report.Lines ||
+		report.report.Parsed != fullReport.report.Parsed ||
+		report.report.Indexed != fullReport.report.Indexed ||
+		report.report.Skipped[parser.ReasonComment] != fullReport.report.Skipped[parser.ReasonComment] {
+		t.Errorf("resumed report: got %+v, want %+v", report.report, fullReport.report)
+	}
+	if cp.offset != total {
+		t.Errorf("checkpoint offset: got %d, want %d", cp.offset, total)
+	}
+}
@@ -0,0 +1,453 @@
+package api
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/x0e1f/dump-hub/common"
+	"github.com/x0e1f/dump-hub/elastic"
+)
+
+type jobReq struct {
+	Job      string `json:"job"`
+	Rollback bool   `json:"rollback"`
+	Priority int    `json:"priority"`
+}
+
+/*
+job :: Running import job, the file reader blocks while the job
+is paused and fails once it is cancelled
+*/
+type job struct {
+	*common.Job
+	ctx      context.Context
+	cancel   context.CancelFunc
+	mutex    sync.Mutex
+	resume   chan struct{}
+	rollback bool
+	result   int
+	progress *importProgress
+}
+
+/*
+jobs :: Running jobs by ID
+*/
+var jobs sync.Map
+
+/*
+newJob :: Create and register an import job
+*/
+func newJob(cs string, fn string, fp string, settings *uploadSettings) *job {
+	return startJob(&common.Job{
+		ID:       uuid.New().String(),
+		Type:     common.JobImport,
+		Date:     time.Now().Format("2006-01-02 15:04:05"),
+		Checksum: cs,
+		Filename: fn,
+		Path:     fp,
+		Source:   settings.source,
+		Config:   settings.config,
+		Priority: settings.priority,
+		Workers:  settings.workers,
+	})
+}
+
+/*
+startJob :: Register an import job, a job stored before a restart
+continues from its checkpoint report
+*/
+func startJob(stored *common.Job) *job {
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		Job:      stored,
+		ctx:      ctx,
+		cancel:   cancel,
+		progress: newImportProgress(),
+	}
+	if stored.Report != nil {
+		j.progress.report = restoreReport(stored.Report)
+	}
+	jobs.Store(j.ID, j)
+
+	return j
+}
+
+/*
+queueJob :: Store job to resume it after a restart, notify clients
+and submit it to the scheduler
+*/
+func queueJob(e *elastic.Client, j *job) {
+	err := e.SaveJob(j.Job)
+	if err != nil {
+		log.Printf("(ERROR) (%s) %s", j.Filename, err)
+	}
+	events.publish(common.EventQueued, j.Checksum, j.Filename, common.StatusProcessing, nil)
+	imports.submit(j)
+}
+
+/*
+resumeJobs :: Resume jobs interrupted by a restart, imports are queued
+in submission order and continue from their last checkpoint,
+deletions, archive extractions and checksums start again
+*/
+func resumeJobs(e *elastic.Client) {
+	stored, err := e.GetJobs()
+	if err != nil {
+		log.Printf("(ERROR) Unable to resume jobs: %s", err)
+		return
+	}
+
+	for _, s := range stored {
+		if s.Type == common.JobDelete {
+			log.Printf("Resuming deletion of %s", s.Checksum)
+			go deleteEntries(e, s)
+			continue
+		}
+		if s.Type == common.JobExtract {
+			log.Printf("Resuming extraction of %s", s.Filename)
+			go processArchive(e, s)
+			continue
+		}
+		if s.Type == common.JobHash {
+			log.Printf("Resuming checksum of %s", s.Filename)
+			go hashFile(e, s)
+			continue
+		}
+
+		log.Printf("Resuming import of %s (%d entries indexed)", s.Filename, s.Offset)
+		j := startJob(s)
+		events.publish(common.EventQueued, j.Checksum, j.Filename, common.StatusProcessing, nil)
+		imports.submit(j)
+	}
+}
+
+/*
+jobSettings :: Upload settings of a stored job, files queued by the
+job are imported from source
+*/
+func jobSettings(j *common.Job, source string) *uploadSettings {
+	return &uploadSettings{
+		config:   j.Config,
+		members:  j.Members,
+		priority: j.Priority,
+		workers:  j.Workers,
+		tags:     j.Tags,
+		source:   source,
+	}
+}
+
+/*
+findJob :: Get running job by ID, nil if not found
+*/
+func findJob(id string) *job {
+	j, ok := jobs.Load(id)
+	if !ok {
+		return nil
+	}
+
+	return j.(*job)
+}
+
+/*
+checksumJob :: Get running job of a file, nil if not found
+*/
+func checksumJob(cs string) *job {
+	var found *job
+	jobs.Range(func(_, value interface{}) bool {
+		j := value.(*job)
+		if j.Checksum == cs {
+			found = j
+			return false
+		}
+		return true
+	})
+
+	return found
+}
+
+/*
+streaming :: Check if the job imports a streaming upload, the data
+is read while the client sends it
+*/
+func (j *job) streaming() bool {
+	return path.Dir(j.Path) == uploadsDir
+}
+
+/*
+done :: Unregister a finished job
+*/
+func (j *job) done() {
+	jobs.Delete(j.ID)
+	j.cancel()
+}
+
+/*
+finish :: Record final history status of the job
+*/
+func (j *job) finish(status int) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	j.result = status
+}
+
+/*
+imported :: Check if job finished with entries indexed, rolled back
+jobs are not imported
+*/
+func (j *job) imported() bool {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	return j.result == common.StatusComplete || j.result == common.StatusPartial
+}
+
+/*
+pause :: Pause job, false if already paused
+*/
+func (j *job) pause() bool {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if j.resume != nil {
+		return false
+	}
+	j.resume = make(chan struct{})
+
+	return true
+}
+
+/*
+unpause :: Resume paused job, false if not paused
+*/
+func (j *job) unpause() bool {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if j.resume == nil {
+		return false
+	}
+	close(j.resume)
+	j.resume = nil
+
+	return true
+}
+
+/*
+stop :: Cancel job, entries already indexed are deleted on rollback
+*/
+func (j *job) stop(rollback bool) {
+	j.mutex.Lock()
+	j.rollback = rollback
+	j.mutex.Unlock()
+
+	j.cancel()
+}
+
+/*
+cancelled :: Check if job was cancelled and if it should be rolled back
+*/
+func (j *job) cancelled() (bool, bool) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	return j.ctx.Err() != nil, j.rollback
+}
+
+/*
+wait :: Block while job is paused, returns an error once cancelled
+*/
+func (j *job) wait() error {
+	j.mutex.Lock()
+	resume := j.resume
+	j.mutex.Unlock()
+
+	if resume != nil {
+		select {
+		case <-resume:
+		case <-j.ctx.Done():
+		}
+	}
+
+	return j.ctx.Err()
+}
+
+/*
+status :: Job progress and queue position
+*/
+func (j *job) status() *common.Progress {
+	status := j.progress.status()
+	status.Job = j.ID
+	status.Queue = imports.position(j)
+	status.Workers = imports.jobWorkers(j)
+
+	j.mutex.Lock()
+	status.Paused = j.resume != nil
+	status.Priority = j.Priority
+	j.mutex.Unlock()
+
+	return status
+}
+
+/*
+reader :: Wrap file reader to count bytes read, pause and cancel
+*/
+func (j *job) reader(r io.Reader) io.Reader {
+	return &jobReader{
+		reader: r,
+		job:    j,
+	}
+}
+
+/*
+jobReader :: File reader of a job
+*/
+type jobReader struct {
+	reader io.Reader
+	job    *job
+}
+
+func (r *jobReader) Read(b []byte) (int, error) {
+	err := r.job.wait()
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := r.reader.Read(b)
+	r.job.progress.add(n)
+	return n, err
+}
+
+/*
+cancelJob :: Cancel a queued or running job (POST)
+*/
+func cancelJob() http.HandlerFunc {
+	return jobHandler(func(j *job, req *jobReq) bool {
+		j.stop(req.Rollback)
+		imports.cancel(j)
+		return true
+	})
+}
+
+/*
+prioritizeJob :: Change priority of a queued job (POST)
+*/
+func prioritizeJob(eClient *elastic.Client) http.HandlerFunc {
+	return jobHandler(func(j *job, req *jobReq) bool {
+		if !imports.prioritize(j, req.Priority) {
+			return false
+		}
+		err := eClient.SaveJob(j.Job)
+		if err != nil {
+			log.Println(err)
+		}
+		events.publish(common.EventQueued, j.Checksum, j.Filename, common.StatusProcessing, j.status())
+		return true
+	})
+}
+
+/*
+pauseJob :: Pause a running job (POST)
+*/
+func pauseJob() http.HandlerFunc {
+	return jobHandler(func(j *job, req *jobReq) bool {
+		if !j.pause() {
+			return false
+		}
+		events.publish(common.EventPaused, j.Checksum, j.Filename, common.StatusProcessing, j.status())
+		return true
+	})
+}
+
+/*
+resumeJob :: Resume a paused job (POST)
+*/
+func resumeJob() http.HandlerFunc {
+	return jobHandler(func(j *job, req *jobReq) bool {
+		if !j.unpause() {
+			return false
+		}
+		events.publish(common.EventResumed, j.Checksum, j.Filename, common.StatusProcessing, j.status())
+		return true
+	})
+}
+
+/*
+jobHandler :: Decode job request and apply action to the job, action
+returns false if the job is not in a valid state (409)
+*/
+func jobHandler(action func(*job, *jobReq) bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var jobReq jobReq
+
+		err := json.NewDecoder(r.Body).Decode(&jobReq)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+
+		j := findJob(jobReq.Job)
+		if j == nil {
+			http.Error(w, "job not found", http.StatusNotFound)
+			return
+		}
+		if !action(j, &jobReq) {
+			http.Error(w, "invalid job state", http.StatusConflict)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+/*
+rollbackJob :: Delete entries of a cancelled job
+*/
+func rollbackJob(e *elastic.Client, j *job) {
+	log.Printf("Rolling back %s", j.Filename)
+
+	/* Stored job is replaced so a restart resumes the rollback */
+	rollback := &common.Job{
+		ID:       j.ID,
+		Type:     common.JobDelete,
+		Date:     j.Date,
+		Checksum: j.Checksum,
+		Filename: j.Filename,
+	}
+	err := e.SaveJob(rollback)
+	if err != nil {
+		log.Println(err)
+	}
+	deleteEntries(e, rollback)
+}
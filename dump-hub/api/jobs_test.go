@@ -0,0 +1,245 @@
+package api
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/x0e1f/dump-hub/common"
+	"github.com/x0e1f/dump-hub/elastic"
+)
+
+/*
+writeDump :: Write a combolist with n entries, returns its path
+*/
+func writeDump(t *testing.T, n int) string {
+	t.Helper()
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("user%d@example.com:pass%d", i, i)
+	}
+	fp := filepath.Join(t.TempDir(), "dump.txt")
+	err := ioutil.WriteFile(fp, []byte(strings.Join(lines, "\n")), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fp
+}
+
+/*
+importDump :: Create history and queue import of a local dump
+*/
+func importDump(t *testing.T, e *elastic.Client, fp string, cs string) *job {
+	t.Helper()
+	settings := &uploadSettings{
+		config: &common.ParserConfig{
+			Pattern: "{:}{#}",
+			Columns: "0:email:email,1:password:password",
+		},
+		source: common.SourceLocal,
+	}
+	j, err := queueHistory(e, settings, filepath.Base(fp), fp, cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return j
+}
+
+/*
+postJob :: Send a job request to a job handler, returns the status code
+*/
+func postJob(handler http.HandlerFunc, req jobReq) int {
+	body, _ := json.Marshal(req)
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)))
+
+	return w.Code
+}
+
+/*
+holdBulk :: Block bulk requests until release is closed, arrived
+receives a value when the first request is blocked
+*/
+func holdBulk(node *testNode) (chan struct{}, chan struct{}) {
+	arrived := make(chan struct{}, 1)
+	release := make(chan struct{})
+	node.setHook(func() {
+		select {
+		case arrived <- struct{}{}:
+		default:
+		}
+		<-release
+	})
+
+	return arrived, release
+}
+
+func waitArrived(t *testing.T, arrived chan struct{}) {
+	t.Helper()
+	select {
+	case <-arrived:
+	case <-time.After(10 * time.Second):
+		t.Fatal("bulk request not received")
+	}
+}
+
+func historyStatus(node *testNode, cs string) interface{} {
+	history := node.doc("dump-hub-history", cs)
+	if history == nil {
+		return nil
+	}
+
+	return history["status"]
+}
+
+func TestPauseJob(t *testing.T) {
+	node, e := newTestNode(t)
+	imports = newScheduler(e, 1, 1)
+	client := events.subscribe()
+	defer events.unsubscribe(client)
+
+	total := 30000
+	arrived, release := holdBulk(node)
+	j := importDump(t, e, writeDump(t, total), "pause")
+	waitArrived(t, arrived)
+
+	if code := postJob(pauseJob(), jobReq{Job: j.ID}); code != http.StatusOK {
+		t.Fatalf("pause: got %d", code)
+	}
+	if code := postJob(pauseJob(), jobReq{Job: j.ID}); code != http.StatusConflict {
+		t.Errorf("pause of a paused job: got %d, want %d", code, http.StatusConflict)
+	}
+	waitEvent(t, client, "pause", common.EventPaused)
+	close(release)
+
+	/* Paused job stops reading the file */
+	status := j.status()
+	for i := 0; i < 50; i++ {
+		time.Sleep(100 * time.Millisecond)
+		next := j.status()
+		if next.BytesRead == status.BytesRead {
+			break
+		}
+		status = next
+	}
+	if !status.Paused || status.BytesRead >= status.Size {
+		t.Errorf("paused job status: %+v", status)
+	}
+	if findJob(j.ID) == nil {
+		t.Fatal("paused job finished")
+	}
+
+	if code := postJob(resumeJob(), jobReq{Job: j.ID}); code != http.StatusOK {
+		t.Fatalf("resume: got %d", code)
+	}
+	waitEvent(t, client, "pause", common.EventCompleted)
+	waitUnregistered(t, j)
+
+	if count := node.entries("pause"); count != total {
+		t.Errorf("indexed entries: got %d, want %d", count, total)
+	}
+	if status := historyStatus(node, "pause"); status != float64(common.StatusComplete) {
+		t.Errorf("history status: got %v", status)
+	}
+	if code := postJob(resumeJob(), jobReq{Job: j.ID}); code != http.StatusNotFound {
+		t.Errorf("resume of a finished job: got %d, want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestCancelJob(t *testing.T) {
+	tests := []struct {
+		name     string
+		running  bool
+		rollback bool
+	}{
+		{name: "queued"},
+		{name: "queued rollback", rollback: true},
+		{name: "running", running: true},
+		{name: "running rollback", running: true, rollback: true},
+	}
+
+	for _, test := range tests {
+		node, e := newTestNode(t)
+		imports = newScheduler(e, 1, 1)
+		client := events.subscribe()
+
+		/* The first import holds the only slot */
+		arrived, release := holdBulk(node)
+		first := importDump(t, e, writeDump(t, 5000), "first")
+		target := first
+		if !test.running {
+			target = importDump(t, e, writeDump(t, 5000), "target")
+			if imports.position(target) != 1 {
+				t.Fatalf("%s: job not queued", test.name)
+			}
+		}
+		waitArrived(t, arrived)
+
+		code := postJob(cancelJob(), jobReq{Job: target.ID, Rollback: test.rollback})
+		if code != http.StatusOK {
+			t.Fatalf("%s: cancel: got %d", test.name, code)
+		}
+		close(release)
+
+		if test.rollback {
+			waitEvent(t, client, target.Checksum, common.EventDeleted)
+		} else {
+			waitEvent(t, client, target.Checksum, common.EventCancelled)
+		}
+		if target != first {
+			waitEvent(t, client, first.Checksum, common.EventCompleted)
+		}
+		waitUnregistered(t, first)
+		waitUnregistered(t, target)
+		events.unsubscribe(client)
+
+		/* Rolled back imports leave no entries and no history */
+		entries := node.entries(target.Checksum)
+		status := historyStatus(node, target.Checksum)
+		switch {
+		case test.rollback && (entries > 0 || status != nil):
+			t.Errorf("%s: %d entries and history status %v after rollback", test.name, entries, status)
+		case !test.rollback && status != float64(common.StatusCancelled):
+			t.Errorf("%s: history status %v, want cancelled", test.name, status)
+		case !test.rollback && test.running && entries < 1:
+			t.Errorf("%s: entries indexed before cancel were removed", test.name)
+		case !test.rollback && !test.running && entries > 0:
+			t.Errorf("%s: %d entries of a job cancelled while queued", test.name, entries)
+		}
+		if node.doc("dump-hub-jobs", target.ID) != nil {
+			t.Errorf("%s: stored job not removed", test.name)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package api
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/x0e1f/dump-hub/common"
+	"github.com/x0e1f/dump-hub/elastic"
+)
+
+/*
+errOutside :: Path outside of the import folder
+*/
+var errOutside = errors.New("path outside of the import folder")
+
+/*
+importLocal :: Import a file of the import folder in place, the request
+is a JSON object with the file path (relative to the import folder) and
+the names of the upload form values
+*/
+func importLocal(eClient *elastic.Client, importDir string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(importDir) < 1 {
+			http.Error(w, "local imports are disabled", http.StatusForbidden)
+			return
+		}
+
+		values := map[string]interface{}{}
+		err := json.NewDecoder(r.Body).Decode(&values)
+		if err != nil {
+			log.Printf("(ERROR) (%s) %s", r.URL, err)
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+		name, _ := values["path"].(string)
+		if len(name) < 1 {
+			http.Error(w, "missing path", http.StatusBadRequest)
+			return
+		}
+
+		filePath, err := localPath(importDir, name)
+		if err == errOutside {
+			log.Printf("(ERROR) (%s) %s: %s", r.URL, err, name)
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if os.IsNotExist(err) {
+			http.Error(w, "file not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("(ERROR) (%s) %s", r.URL, err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		/* Validate settings from request values */
+		settings, err := readSettings(jsonValue(values))
+		if err != nil {
+			log.Printf("(ERROR) Parser creation error: (%s)", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		settings.source = common.SourceLocal
+
+		/* Large files are hashed in background */
+		j := queueHash(eClient, settings, filepath.Base(filePath), filePath)
+		writeJob(w, j, nil)
+	}
+}
+
+/*
+queueHash :: Store and start the checksum job of a local file, the
+file is imported once hashed
+*/
+func queueHash(e *elastic.Client, settings *uploadSettings, fn string, fp string) *common.Job {
+	j := &common.Job{
+		ID:       uuid.New().String(),
+		Type:     common.JobHash,
+		Date:     time.Now().Format("2006-01-02 15:04:05"),
+		Filename: fn,
+		Path:     fp,
+		Source:   settings.source,
+		Config:   settings.config,
+		Priority: settings.priority,
+		Workers:  settings.workers,
+		Members:  settings.members,
+		Tags:     settings.tags,
+	}
+	err := e.SaveJob(j)
+	if err != nil {
+		log.Printf("(ERROR) (%s) %s", fn, err)
+	}
+	go hashFile(e, j)
+
+	return j
+}
+
+/*
+hashFile :: Compute checksum of a local file and submit its import,
+clients are notified of duplicated files with a duplicate event
+*/
+func hashFile(e *elastic.Client, j *common.Job) {
+	log.Printf("Hashing %s", j.Filename)
+	checkSum, err := fileChecksum(j.Path)
+	if err == nil {
+		_, err = submitUpload(e, jobSettings(j, j.Source), j.Filename, j.Path, checkSum)
+	}
+
+	/* The import job is stored before the checksum job is removed */
+	dErr := e.DeleteJob(j.ID)
+	if dErr != nil {
+		log.Println(dErr)
+	}
+	switch {
+	case err == errDuplicate:
+		events.publish(common.EventDuplicate, checkSum, j.Filename, common.StatusFailed, nil)
+	case err != nil:
+		log.Printf("(ERROR) (%s) %s", j.Filename, err)
+		events.publish(common.EventFailed, checkSum, j.Filename, common.StatusFailed, nil)
+	}
+}
+
+/*
+localPath :: Resolve path of a regular file of the import folder, paths
+and symbolic links leading outside of the folder are rejected
+*/
+func localPath(importDir string, name string) (string, error) {
+	root, err := filepath.Abs(importDir)
+	if err != nil {
+		return "", err
+	}
+	fp := name
+	if !filepath.IsAbs(fp) {
+		fp = filepath.Join(root, fp)
+	}
+	if !inside(root, filepath.Clean(fp)) {
+		return "", errOutside
+	}
+
+	/* Links are checked once resolved */
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	fp, err = filepath.EvalSymlinks(fp)
+	if err != nil {
+		return "", err
+	}
+	if !inside(root, fp) {
+		return "", errOutside
+	}
+
+	info, err := os.Stat(fp)
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", errors.New("not a regular file")
+	}
+
+	return fp, nil
+}
+
+/*
+inside :: Check if path is inside of the root folder
+*/
+func inside(root string, fp string) bool {
+	rel, err := filepath.Rel(root, fp)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,188 @@
+package api
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/x0e1f/dump-hub/common"
+)
+
+func TestLocalPath(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "import")
+	for _, dir := range []string{root, filepath.Join(root, "sub"), filepath.Join(base, "import2")} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, fp := range []string{
+		filepath.Join(root, "dump.txt"),
+		filepath.Join(root, "..dump.txt"),
+		filepath.Join(base, "secret.txt"),
+		filepath.Join(base, "import2", "dump.txt"),
+	} {
+		if err := ioutil.WriteFile(fp, []byte("a@b.c:pw\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"link":   filepath.Join(base, "secret.txt"),
+		"out":    base,
+		"inlink": filepath.Join(root, "dump.txt"),
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	/* Temporary folder may itself be a link */
+	resolved, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dump := filepath.Join(resolved, "dump.txt")
+
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		outside bool
+	}{
+		{name: "relative", path: "dump.txt", want: dump},
+		{name: "cleaned", path: "sub/../dump.txt", want: dump},
+		{name: "absolute inside", path: filepath.Join(root, "dump.txt"), want: dump},
+		{name: "dots in name", path: "..dump.txt", want: filepath.Join(resolved, "..dump.txt")},
+		{name: "link inside", path: "inlink", want: dump},
+		{name: "traversal", path: "../secret.txt", outside: true},
+		{name: "nested traversal", path: "sub/../../secret.txt", outside: true},
+		{name: "absolute outside", path: filepath.Join(base, "secret.txt"), outside: true},
+		{name: "sibling prefix", path: "../import2/dump.txt", outside: true},
+		{name: "absolute sibling prefix", path: filepath.Join(base, "import2", "dump.txt"), outside: true},
+		{name: "link outside", path: "link", outside: true},
+		{name: "linked folder outside", path: "out/secret.txt", outside: true},
+	}
+
+	for _, test := range tests {
+		fp, err := localPath(root, test.path)
+		if test.outside {
+			if err != errOutside {
+				t.Errorf("%s: got %q (%v), want %s", test.name, fp, err, errOutside)
+			}
+			continue
+		}
+		if err != nil || fp != test.want {
+			t.Errorf("%s: got %q (%v), want %q", test.name, fp, err, test.want)
+		}
+	}
+
+	if _, err := localPath(root, "missing.txt"); !os.IsNotExist(err) {
+		t.Errorf("missing file: got %v", err)
+	}
+	if _, err := localPath(root, "sub"); err == nil || err == errOutside {
+		t.Errorf("folder: got %v", err)
+	}
+}
+
+func TestInside(t *testing.T) {
+	tests := []struct {
+		path   string
+		inside bool
+	}{
+		{"/data/import", true},
+		{"/data/import/dump.txt", true},
+		{"/data/import/sub/dump.txt", true},
+		{"/data/import/..dump.txt", true},
+		{"/data", false},
+		{"/data/secret.txt", false},
+		{"/data/import2", false},
+		{"/data/import2/dump.txt", false},
+		{"/", false},
+	}
+
+	for _, test := range tests {
+		if got := inside("/data/import", test.path); got != test.inside {
+			t.Errorf("%s: got %t, want %t", test.path, got, test.inside)
+		}
+	}
+}
+
+func TestImportLocal(t *testing.T) {
+	node, e := newTestNode(t)
+	imports = newScheduler(e, 1, 1)
+	client := events.subscribe()
+	defer events.unsubscribe(client)
+
+	fp := writeDump(t, 100)
+	data, err := ioutil.ReadFile(fp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := sha256.Sum256(data)
+	checkSum := hex.EncodeToString(hash[:])
+
+	body := `{"path": "dump.txt", "pattern": "{:}{#}", "columns": "0,1", "tags": ["local"]}`
+	for i, want := range []string{common.EventCompleted, common.EventDuplicate} {
+		w := httptest.NewRecorder()
+		importLocal(e, filepath.Dir(fp))(w, httptest.NewRequest(http.MethodPost, "/api/import", strings.NewReader(body)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("import %d: got %d", i, w.Code)
+		}
+
+		/* The checksum job is returned before hashing the file */
+		j := &common.Job{}
+		json.Unmarshal(w.Body.Bytes(), j)
+		if j.Type != common.JobHash || j.Filename != "dump.txt" || len(j.Checksum) > 0 {
+			t.Errorf("import %d: got job %+v", i, j)
+		}
+		event := waitEvent(t, client, checkSum, want)
+		if event.Filename != "dump.txt" {
+			t.Errorf("import %d: %s event of %q", i, want, event.Filename)
+		}
+		for n := 0; node.count("dump-hub-jobs") > 0; n++ {
+			if n > 500 {
+				t.Fatalf("import %d: stored jobs not removed", i)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	if count := node.entries(checkSum); count != 100 {
+		t.Errorf("indexed entries: got %d, want 100", count)
+	}
+	if _, err := os.Stat(fp); err != nil {
+		t.Errorf("local file removed: %s", err)
+	}
+}
@@ -0,0 +1,296 @@
+package api
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/x0e1f/dump-hub/common"
+	"github.com/x0e1f/dump-hub/elastic"
+)
+
+/*
+testNode :: In memory elasticsearch node serving the document, bulk
+and delete by query requests of the engine. Bulk requests call hook
+before indexing documents
+*/
+type testNode struct {
+	mutex   sync.Mutex
+	indices map[string]map[string]map[string]interface{}
+	hook    func()
+}
+
+/*
+newTestNode :: Start a test node and connect a client to it
+*/
+func newTestNode(t *testing.T) (*testNode, *elastic.Client) {
+	t.Helper()
+	node := &testNode{
+		indices: map[string]map[string]map[string]interface{}{},
+	}
+	server := httptest.NewServer(node)
+	t.Cleanup(server.Close)
+
+	e, err := elastic.Connect(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return node, e
+}
+
+/*
+newTestEngine :: Serve the API routes with a test node client
+*/
+func newTestEngine(t *testing.T, e *elastic.Client) *httptest.Server {
+	t.Helper()
+	engine := &Engine{
+		baseAPI: "/api/",
+		eClient: e,
+	}
+	engine.defineRoutes()
+	server := httptest.NewServer(engine.router)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+/*
+setHook :: Set function called by bulk requests
+*/
+func (n *testNode) setHook(hook func()) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.hook = hook
+}
+
+/*
+doc :: Get document source, nil if not found
+*/
+func (n *testNode) doc(index string, id string) map[string]interface{} {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	return n.indices[index][id]
+}
+
+/*
+count :: Number of documents of an index
+*/
+func (n *testNode) count(index string) int {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	return len(n.indices[index])
+}
+
+/*
+entries :: Number of entries of a file
+*/
+func (n *testNode) entries(cs string) int {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	count := 0
+	for _, source := range n.indices["dump-hub"] {
+		if source["origin_id"] == cs {
+			count++
+		}
+	}
+
+	return count
+}
+
+/*
+put :: Store document source
+*/
+func (n *testNode) put(index string, id string, source map[string]interface{}) {
+	if n.indices[index] == nil {
+		n.indices[index] = map[string]map[string]interface{}{}
+	}
+	n.indices[index][id] = source
+}
+
+func (n *testNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	if len(parts) == 1 && parts[0] == "_bulk" {
+		n.mutex.Lock()
+		hook := n.hook
+		n.mutex.Unlock()
+		if hook != nil {
+			hook()
+		}
+		n.bulk(w, r)
+		return
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	switch {
+	case len(parts) == 2 && parts[1] == "_refresh":
+		w.Write([]byte(`{"_shards":{"total":1,"successful":1,"failed":0}}`))
+
+	case len(parts) == 2 && parts[1] == "_delete_by_query":
+		var body struct {
+			Query struct {
+				Match map[string]struct {
+					Query string `json:"query"`
+				} `json:"match"`
+			} `json:"query"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		deleted := 0
+		for id, source := range n.indices[parts[0]] {
+			if match, ok := body.Query.Match["origin_id"]; ok && source["origin_id"] == match.Query {
+				delete(n.indices[parts[0]], id)
+				deleted++
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"deleted": deleted, "failures": []interface{}{}})
+
+	case len(parts) == 3 && parts[1] == "_update":
+		source := n.indices[parts[0]][parts[2]]
+		if source == nil {
+			writeJSON(w, http.StatusNotFound, map[string]interface{}{
+				"error":  map[string]string{"type": "document_missing_exception", "reason": "document missing"},
+				"status": http.StatusNotFound,
+			})
+			return
+		}
+		var body struct {
+			Doc map[string]interface{} `json:"doc"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		for key, value := range body.Doc {
+			source[key] = value
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"_index": parts[0], "_id": parts[2], "result": "updated"})
+
+	case len(parts) == 3 && parts[1] == "_doc":
+		source := n.indices[parts[0]][parts[2]]
+		status := http.StatusOK
+		if source == nil {
+			status = http.StatusNotFound
+		}
+		switch r.Method {
+		case http.MethodHead:
+			w.WriteHeader(status)
+		case http.MethodGet:
+			writeJSON(w, status, map[string]interface{}{"_index": parts[0], "_id": parts[2], "found": source != nil, "_source": source})
+		case http.MethodDelete:
+			delete(n.indices[parts[0]], parts[2])
+			writeJSON(w, status, map[string]interface{}{"_index": parts[0], "_id": parts[2], "result": "deleted"})
+		default:
+			source = map[string]interface{}{}
+			json.NewDecoder(r.Body).Decode(&source)
+			n.put(parts[0], parts[2], source)
+			writeJSON(w, http.StatusCreated, map[string]interface{}{"_index": parts[0], "_id": parts[2], "result": "created"})
+		}
+
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"error":  map[string]string{"type": "illegal_argument_exception", "reason": r.Method + " " + r.URL.Path},
+			"status": http.StatusBadRequest,
+		})
+	}
+}
+
+/*
+bulk :: Index documents of a bulk request
+*/
+func (n *testNode) bulk(w http.ResponseWriter, r *http.Request) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	items := []interface{}{}
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(nil, elastic.BulkSize*2)
+	for scanner.Scan() {
+		var action map[string]struct {
+			Index string `json:"_index"`
+			ID    string `json:"_id"`
+		}
+		json.Unmarshal(scanner.Bytes(), &action)
+		if !scanner.Scan() {
+			break
+		}
+		source := map[string]interface{}{}
+		json.Unmarshal(scanner.Bytes(), &source)
+
+		meta := action["index"]
+		n.put(meta.Index, meta.ID, source)
+		items = append(items, map[string]interface{}{
+			"index": map[string]interface{}{"_index": meta.Index, "_id": meta.ID, "status": http.StatusCreated, "result": "created"},
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"took": 1, "errors": false, "items": items})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	data, _ := json.Marshal(body)
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+/*
+waitEvent :: Wait for an event of a file
+*/
+func waitEvent(t *testing.T, client chan *common.Event, cs string, eventType string) *common.Event {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case event := <-client:
+			if event.Checksum == cs && event.Type == eventType {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("%s: %s event not received", cs, eventType)
+		}
+	}
+}
+
+/*
+waitUnregistered :: Wait until a finished job is unregistered
+*/
+func waitUnregistered(t *testing.T, j *job) {
+	t.Helper()
+	for i := 0; findJob(j.ID) != nil; i++ {
+		if i > 1000 {
+			t.Fatalf("%s: job still registered", j.Filename)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
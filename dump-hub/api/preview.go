@@ -0,0 +1,214 @@
+package api
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/x0e1f/dump-hub/common"
+	"github.com/x0e1f/dump-hub/parser"
+	"github.com/x0e1f/dump-hub/stream"
+)
+
+/* Entries and skipped lines returned by preview */
+const maxPreviewRows = 100
+
+/*
+preview :: Parse a file sample with the upload parser settings (POST).
+The sample is the file form value, its size value is the size of the
+whole file, or the beginning of a resumable upload (upload value)
+*/
+func preview() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.ParseMultipartForm(maxSampleSize)
+
+		var p *parser.Parser
+		config, err := parserConfig(r.FormValue)
+		if err == nil {
+			p, err = newParser(config)
+		}
+		if err != nil {
+			log.Printf("(ERROR) Parser creation error: (%s)", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		sample, filename, partial, err := previewSample(r)
+		if os.IsNotExist(err) {
+			http.Error(w, "upload not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("(ERROR) (%s) %s", r.URL, err)
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+
+		result, err := previewEntries(p, sample, partial, filename, config.Encoding)
+		if err != nil {
+			log.Printf("(ERROR) (%s) %s", r.URL, err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		response, err := json.Marshal(result)
+		if err != nil {
+			http.Error(w, "", http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response)
+	}
+}
+
+/*
+previewSample :: Read the sample of a preview request, along with the
+file name and if the sample is only the beginning of the file
+*/
+func previewSample(r *http.Request) ([]byte, string, bool, error) {
+	if id := r.FormValue("upload"); len(id) > 0 {
+		u, err := loadUpload(id)
+		if err != nil {
+			return nil, "", false, err
+		}
+		if u == nil {
+			return nil, "", false, os.ErrNotExist
+		}
+		file, err := os.Open(u.dataPath())
+		if err != nil {
+			return nil, "", false, err
+		}
+		defer file.Close()
+
+		sample, err := ioutil.ReadAll(io.LimitReader(file, maxSampleSize))
+		return sample, u.filename(), int64(len(sample)) < u.Length, err
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return nil, "", false, err
+	}
+	defer file.Close()
+
+	sample, err := ioutil.ReadAll(io.LimitReader(file, maxSampleSize))
+	if err != nil {
+		return nil, "", false, err
+	}
+	size, _ := strconv.ParseInt(r.FormValue("size"), 10, 64)
+
+	return sample, header.Filename, int64(len(sample)) < size, nil
+}
+
+/*
+sampleText :: Decompress and convert a file sample to UTF-8 like an
+import does, returns the text with the encoding and compression names
+and if the text is only the beginning of the file. The last line of
+a partial sample is left out, it may be cut
+*/
+func sampleText(sample []byte, partial bool, encoding string) ([]byte, string, string, bool, error) {
+	reader, compression, err := stream.Decompress(bytes.NewReader(sample))
+	if err != nil {
+		return nil, "", "", false, err
+	}
+	defer reader.Close()
+
+	/* Compressed samples end with a cut stream */
+	data, err := ioutil.ReadAll(io.LimitReader(reader, maxSampleSize+1))
+	if err != nil && !partial {
+		return nil, "", "", false, err
+	}
+	if len(data) > maxSampleSize {
+		data = data[:maxSampleSize]
+		partial = true
+	}
+
+	text, enc, err := stream.Transcode(bytes.NewReader(data), encoding)
+	if err != nil {
+		return nil, "", "", false, err
+	}
+	data, err = ioutil.ReadAll(text)
+	if err != nil {
+		return nil, "", "", false, err
+	}
+	if i := bytes.LastIndexByte(data, '\n'); partial && i >= 0 {
+		data = data[:i+1]
+	}
+
+	return data, enc, compression, partial, nil
+}
+
+/*
+previewEntries :: Parse a file sample like an import does
+*/
+func previewEntries(p *parser.Parser, sample []byte, partial bool, filename string, encoding string) (*common.Preview, error) {
+	data, enc, compression, partial, err := sampleText(sample, partial, encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &common.Preview{
+		Entries:      []*common.Entry{},
+		Skipped:      map[string]int{},
+		SkippedLines: []*common.Rejected{},
+		Encoding:     enc,
+		Compression:  compression,
+		Truncated:    partial,
+	}
+	err = p.Parse(bytes.NewReader(data), filename, "", func(entry *common.Entry) {
+		result.Parsed++
+		if len(result.Entries) < maxPreviewRows {
+			result.Entries = append(result.Entries, entry)
+		}
+	}, func(line *parser.Skipped) {
+		result.Skipped[line.Reason]++
+		if len(result.SkippedLines) >= maxPreviewRows {
+			return
+		}
+
+		raw := line.Raw
+		if len(raw) > maxRejectedLength {
+			raw = raw[:maxRejectedLength]
+		}
+		result.SkippedLines = append(result.SkippedLines, &common.Rejected{
+			Line:   line.Line,
+			Raw:    raw,
+			Reason: line.Reason,
+		})
+	})
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	return result, nil
+}
@@ -0,0 +1,158 @@
+package api
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/x0e1f/dump-hub/common"
+)
+
+type progressReq struct {
+	Checksum string `json:"checksum"`
+}
+
+/*
+importProgress :: Progress of an import
+*/
+type importProgress struct {
+	mutex  sync.Mutex
+	size   int64
+	read   int64
+	start  time.Time
+	report *importReport
+}
+
+/*
+newImportProgress :: Create progress of a queued import
+*/
+func newImportProgress() *importProgress {
+	return &importProgress{
+		report: newImportReport(),
+	}
+}
+
+/*
+begin :: Start measuring progress of a file
+*/
+func (p *importProgress) begin(size int64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.size = size
+	p.start = time.Now()
+}
+
+/*
+add :: Count bytes read from file
+*/
+func (p *importProgress) add(n int) {
+	atomic.AddInt64(&p.read, int64(n))
+}
+
+/*
+status :: Compute progress, throughput and ETA
+*/
+func (p *importProgress) status() *common.Progress {
+	p.mutex.Lock()
+	size, start := p.size, p.start
+	p.mutex.Unlock()
+
+	report := p.report.snapshot()
+	status := &common.Progress{
+		Size:      size,
+		BytesRead: atomic.LoadInt64(&p.read),
+		Lines:     report.Lines,
+		Parsed:    report.Parsed,
+		Indexed:   report.Indexed,
+		Failed:    report.Failed,
+		ETA:       -1,
+	}
+	if !start.IsZero() {
+		status.Seconds = time.Since(start).Seconds()
+	}
+	if status.Size > 0 {
+		status.Percent = float64(status.BytesRead) * 100 / float64(status.Size)
+	}
+	if status.Seconds > 0 {
+		status.DocsPerSecond = float64(status.Indexed) / status.Seconds
+		status.BytesPerSecond = float64(status.BytesRead) / status.Seconds
+	}
+	/* ETA is unknown (-1) without file size or throughput */
+	if status.Size > 0 && status.BytesPerSecond > 0 {
+		status.ETA = float64(status.Size-status.BytesRead) / status.BytesPerSecond
+	}
+
+	return status
+}
+
+/*
+importStatus :: Get progress of a running import, nil if the
+import is not running
+*/
+func importStatus(cs string) *common.Progress {
+	j := checksumJob(cs)
+	if j == nil {
+		return nil
+	}
+
+	return j.status()
+}
+
+/*
+getProgress :: Get progress of a running import
+*/
+func getProgress() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var progressReq progressReq
+
+		err := json.NewDecoder(r.Body).Decode(&progressReq)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+
+		progress := importStatus(progressReq.Checksum)
+		if progress == nil {
+			http.Error(w, "import not running", http.StatusNotFound)
+			return
+		}
+		response, err := json.Marshal(progress)
+		if err != nil {
+			http.Error(w, "", http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response)
+	}
+}
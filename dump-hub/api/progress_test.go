@@ -0,0 +1,76 @@
+package api
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/x0e1f/dump-hub/elastic"
+)
+
+func TestProgressStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int64
+		read    int
+		indexed int
+		elapsed time.Duration
+		percent float64
+		docs    float64
+		bytes   float64
+		eta     float64
+	}{
+		{name: "queued", size: 0, eta: -1},
+		{name: "started", size: 1000, eta: -1},
+		{name: "running", size: 1000, read: 250, indexed: 50, elapsed: 10 * time.Second, percent: 25, docs: 5, bytes: 25, eta: 30},
+		{name: "finished", size: 1000, read: 1000, indexed: 200, elapsed: 20 * time.Second, percent: 100, docs: 10, bytes: 50, eta: 0},
+		{name: "unknown size", read: 500, indexed: 10, elapsed: 5 * time.Second, docs: 2, bytes: 100, eta: -1},
+	}
+
+	near := func(got float64, want float64) bool {
+		return math.Abs(got-want) <= math.Abs(want)*0.01+0.001
+	}
+	for _, test := range tests {
+		p := newImportProgress()
+		if test.size > 0 || test.elapsed > 0 {
+			p.begin(test.size)
+			p.start = time.Now().Add(-test.elapsed)
+		}
+		p.add(test.read)
+		p.report.indexed(&elastic.BulkResult{Indexed: test.indexed}, 0)
+
+		status := p.status()
+		if status.Size != test.size || status.BytesRead != int64(test.read) || status.Indexed != test.indexed {
+			t.Errorf("%s: got %+v", test.name, status)
+		}
+		if !near(status.Percent, test.percent) || !near(status.DocsPerSecond, test.docs) ||
+			!near(status.BytesPerSecond, test.bytes) || !near(status.ETA, test.eta) {
+			t.Errorf("%s: got %.2f%%, %.2f docs/s, %.2f bytes/s, ETA %.2f, want %.2f%%, %.2f docs/s, %.2f bytes/s, ETA %.2f",
+				test.name, status.Percent, status.DocsPerSecond, status.BytesPerSecond, status.ETA,
+				test.percent, test.docs, test.bytes, test.eta)
+		}
+	}
+}
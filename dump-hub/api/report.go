@@ -0,0 +1,245 @@
+package api
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/x0e1f/dump-hub/common"
+	"github.com/x0e1f/dump-hub/elastic"
+	"github.com/x0e1f/dump-hub/parser"
+)
+
+/* Rejected lines samples kept in a report */
+const maxRejected = 100
+
+/* Maximum length of a rejected line sample */
+const maxRejectedLength = 512
+
+type reportReq struct {
+	Checksum string `json:"checksum"`
+}
+
+/*
+importReport :: Import report shared by parser and uploaders
+*/
+type importReport struct {
+	mutex  sync.Mutex
+	report common.Report
+}
+
+/*
+newImportReport :: Create empty import report
+*/
+func newImportReport() *importReport {
+	return &importReport{
+		report: common.Report{
+			Skipped:  map[string]int{},
+			Errors:   map[string]int{},
+			Rejected: []*common.Rejected{},
+		},
+	}
+}
+
+/*
+restoreReport :: Create import report from a stored report, nil
+creates an empty report
+*/
+func restoreReport(stored *common.Report) *importReport {
+	r := newImportReport()
+	if stored != nil {
+		r.merge(stored)
+	}
+
+	return r
+}
+
+/*
+parsed :: Count a parsed entry
+*/
+func (r *importReport) parsed() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.report.Lines++
+	r.report.Parsed++
+}
+
+/*
+skipped :: Count a skipped line, rejected lines are sampled
+*/
+func (r *importReport) skipped(line *parser.Skipped) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.report.Lines++
+	r.report.Skipped[line.Reason]++
+	if !line.Rejected() || len(r.report.Rejected) >= maxRejected {
+		return
+	}
+
+	raw := line.Raw
+	if len(raw) > maxRejectedLength {
+		raw = raw[:maxRejectedLength]
+	}
+	r.report.Rejected = append(r.report.Rejected, &common.Rejected{
+		Line:   line.Line,
+		Raw:    raw,
+		Reason: line.Reason,
+	})
+}
+
+/*
+indexed :: Count documents sent to elastic
+*/
+func (r *importReport) indexed(result *elastic.BulkResult, bytes int64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.report.Indexed += result.Indexed
+	r.report.Failed += result.Failed
+	r.report.Retries += result.Retries
+	r.report.Bytes += bytes
+	for errorType, count := range result.Errors {
+		r.report.Errors[errorType] += count
+	}
+}
+
+/*
+merge :: Add counters and rejected lines samples of another report
+*/
+func (r *importReport) merge(other *common.Report) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.report.Lines += other.Lines
+	r.report.Parsed += other.Parsed
+	r.report.Indexed += other.Indexed
+	r.report.Failed += other.Failed
+	r.report.Retries += other.Retries
+	r.report.Bytes += other.Bytes
+	for reason, count := range other.Skipped {
+		r.report.Skipped[reason] += count
+	}
+	for errorType, count := range other.Errors {
+		r.report.Errors[errorType] += count
+	}
+	for _, rejected := range other.Rejected {
+		if len(r.report.Rejected) >= maxRejected {
+			break
+		}
+		r.report.Rejected = append(r.report.Rejected, rejected)
+	}
+}
+
+/*
+snapshot :: Copy of report counters
+*/
+func (r *importReport) snapshot() common.Report {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return common.Report{
+		Lines:   r.report.Lines,
+		Parsed:  r.report.Parsed,
+		Indexed: r.report.Indexed,
+		Failed:  r.report.Failed,
+		Retries: r.report.Retries,
+		Bytes:   r.report.Bytes,
+	}
+}
+
+/*
+finish :: Compute indexing throughput
+*/
+func (r *importReport) finish(elapsed time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.report.Seconds = elapsed.Seconds()
+	if r.report.Seconds > 0 {
+		r.report.DocsPerSecond = float64(r.report.Indexed) / r.report.Seconds
+		r.report.BytesPerSecond = float64(r.report.Bytes) / r.report.Seconds
+	}
+}
+
+/*
+rejected :: Check if some lines were rejected or not indexed
+*/
+func (r *importReport) rejected() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.report.Failed > 0 {
+		return true
+	}
+	for reason, count := range r.report.Skipped {
+		if count > 0 && parser.IsRejection(reason) {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+getReport :: Get import report of a file
+*/
+func getReport(eClient *elastic.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var reportReq reportReq
+
+		err := json.NewDecoder(r.Body).Decode(&reportReq)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+
+		report, err := eClient.GetReport(reportReq.Checksum)
+		if err != nil {
+			log.Printf("(ERROR) (%s) %s", r.URL, err)
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		if report == nil {
+			http.Error(w, "report not found", http.StatusNotFound)
+			return
+		}
+		response, err := json.Marshal(report)
+		if err != nil {
+			http.Error(w, "", http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response)
+	}
+}
@@ -43,12 +43,108 @@ func (engine *Engine) defineRoutes() {
 		Methods(http.MethodPost).
 		HandlerFunc(upload(engine.eClient))
 
+	router.
+		Name("CreateUpload").
+		Path(engine.baseAPI + "uploads").
+		Methods(http.MethodPost).
+		HandlerFunc(createUpload(engine.eClient))
+
+	router.
+		Name("UploadOptions").
+		Path(engine.baseAPI + "uploads").
+		Methods(http.MethodOptions).
+		HandlerFunc(tusOptions())
+
+	router.
+		Name("UploadOffset").
+		Path(engine.baseAPI + "uploads/{id}").
+		Methods(http.MethodHead).
+		HandlerFunc(uploadOffset())
+
+	router.
+		Name("PatchUpload").
+		Path(engine.baseAPI + "uploads/{id}").
+		Methods(http.MethodPatch).
+		HandlerFunc(patchUpload(engine.eClient))
+
+	router.
+		Name("TerminateUpload").
+		Path(engine.baseAPI + "uploads/{id}").
+		Methods(http.MethodDelete).
+		HandlerFunc(terminateUpload())
+
+	router.
+		Name("Import").
+		Path(engine.baseAPI + "import").
+		Methods(http.MethodPost).
+		HandlerFunc(importLocal(engine.eClient, engine.importDir))
+
+	router.
+		Name("Schema").
+		Path(engine.baseAPI + "schema").
+		Methods(http.MethodPost).
+		HandlerFunc(schema())
+
+	router.
+		Name("Preview").
+		Path(engine.baseAPI + "preview").
+		Methods(http.MethodPost).
+		HandlerFunc(preview())
+
+	router.
+		Name("Detect").
+		Path(engine.baseAPI + "detect").
+		Methods(http.MethodPost).
+		HandlerFunc(detect())
+
 	router.
 		Name("History").
 		Path(engine.baseAPI + "history").
 		Methods(http.MethodPost).
 		HandlerFunc(getHistory(engine.eClient))
 
+	router.
+		Name("Report").
+		Path(engine.baseAPI + "report").
+		Methods(http.MethodPost).
+		HandlerFunc(getReport(engine.eClient))
+
+	router.
+		Name("Progress").
+		Path(engine.baseAPI + "progress").
+		Methods(http.MethodPost).
+		HandlerFunc(getProgress())
+
+	router.
+		Name("Events").
+		Path(engine.baseAPI + "events").
+		Methods(http.MethodGet).
+		HandlerFunc(streamEvents())
+
+	router.
+		Name("Cancel").
+		Path(engine.baseAPI + "cancel").
+		Methods(http.MethodPost).
+		HandlerFunc(cancelJob())
+
+	router.
+		Name("Pause").
+		Path(engine.baseAPI + "pause").
+		Methods(http.MethodPost).
+		HandlerFunc(pauseJob())
+
+	router.
+		Name("Resume").
+		Path(engine.baseAPI + "resume").
+		Methods(http.MethodPost).
+		HandlerFunc(resumeJob())
+
+	router.
+		Name("Priority").
+		Path(engine.baseAPI + "priority").
+		Methods(http.MethodPost).
+		HandlerFunc(prioritizeJob(engine.eClient))
+
 	router.
 		Name("Search").
 		Path(engine.baseAPI + "search").
@@ -59,7 +155,7 @@ func (engine *Engine) defineRoutes() {
 		Name("Delete").
 		Path(engine.baseAPI + "delete").
 		Methods(http.MethodPost).
-		HandlerFunc(delete(engine.eClient))
+		HandlerFunc(deleteHistory(engine.eClient))
 
 	engine.router = router
 }
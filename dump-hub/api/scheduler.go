@@ -0,0 +1,227 @@
+package api
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"log"
+	"runtime"
+	"sync"
+
+	"github.com/x0e1f/dump-hub/elastic"
+)
+
+/* Maximum uploader routines of an import */
+const maxWorkers = 64
+
+/*
+scheduler :: Import jobs queue, at most maxJobs imports run at the
+same time. Streaming imports wait for the data sent by the client,
+they have their own maxJobs slots so they do not keep other imports
+waiting. Queued jobs are ordered by priority (higher first) and by
+submission time
+*/
+type scheduler struct {
+	mutex   sync.Mutex
+	process func(*job)
+	maxJobs int
+	workers int
+	running int
+	streams int
+	queue   []*job
+}
+
+/*
+imports :: Scheduler of import jobs
+*/
+var imports *scheduler
+
+/*
+newScheduler :: Create import scheduler, workers is the default
+number of uploader routines of a job (0 uses the number of CPUs)
+*/
+func newScheduler(eClient *elastic.Client, maxJobs int, workers int) *scheduler {
+	if maxJobs < 1 {
+		maxJobs = 1
+	}
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	log.Printf("Scheduling %d concurrent imports (%d workers)", maxJobs, workers)
+
+	return &scheduler{
+		process: func(j *job) {
+			processFile(eClient, j)
+		},
+		maxJobs: maxJobs,
+		workers: workers,
+	}
+}
+
+/*
+submit :: Queue job and start it if there is a free slot
+*/
+func (s *scheduler) submit(j *job) {
+	s.mutex.Lock()
+	s.insert(j)
+	s.mutex.Unlock()
+
+	s.dispatch()
+}
+
+/*
+insert :: Add job after the queued jobs with the same or higher priority
+*/
+func (s *scheduler) insert(j *job) {
+	i := len(s.queue)
+	for i > 0 && s.queue[i-1].Priority < j.Priority {
+		i--
+	}
+	s.queue = append(s.queue, nil)
+	copy(s.queue[i+1:], s.queue[i:])
+	s.queue[i] = j
+}
+
+/*
+dequeue :: Remove job from queue, false if the job is not queued
+*/
+func (s *scheduler) dequeue(j *job) bool {
+	for i, queued := range s.queue {
+		if queued == j {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+dispatch :: Start queued jobs that have a free slot
+*/
+func (s *scheduler) dispatch() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i := 0; i < len(s.queue); {
+		j := s.queue[i]
+		if !s.acquire(j) {
+			i++
+			continue
+		}
+		s.queue = append(s.queue[:i], s.queue[i+1:]...)
+		go s.run(j)
+	}
+}
+
+/*
+acquire :: Take a slot for a job, false if there is no free slot
+*/
+func (s *scheduler) acquire(j *job) bool {
+	slots := &s.running
+	if j.streaming() {
+		slots = &s.streams
+	}
+	if *slots >= s.maxJobs {
+		return false
+	}
+	*slots++
+
+	return true
+}
+
+/*
+run :: Process job and release its slot
+*/
+func (s *scheduler) run(j *job) {
+	s.process(j)
+
+	s.mutex.Lock()
+	if j.streaming() {
+		s.streams--
+	} else {
+		s.running--
+	}
+	s.mutex.Unlock()
+
+	s.dispatch()
+}
+
+/*
+cancel :: Cancelled jobs do not wait for a free slot
+*/
+func (s *scheduler) cancel(j *job) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.dequeue(j) {
+		go s.process(j)
+	}
+}
+
+/*
+prioritize :: Change priority of a queued job, false if the job
+is not queued
+*/
+func (s *scheduler) prioritize(j *job, priority int) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.dequeue(j) {
+		return false
+	}
+	j.mutex.Lock()
+	j.Priority = priority
+	j.mutex.Unlock()
+	s.insert(j)
+
+	return true
+}
+
+/*
+position :: Queue position of a job starting from 1, 0 if the job
+is not queued
+*/
+func (s *scheduler) position(j *job) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i, queued := range s.queue {
+		if queued == j {
+			return i + 1
+		}
+	}
+
+	return 0
+}
+
+/*
+jobWorkers :: Uploader routines of a job
+*/
+func (s *scheduler) jobWorkers(j *job) int {
+	if j.Workers < 1 {
+		return s.workers
+	}
+
+	return j.Workers
+}
@@ -0,0 +1,228 @@
+package api
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"path"
+	"testing"
+	"time"
+
+	"github.com/x0e1f/dump-hub/common"
+)
+
+/*
+testScheduler :: Scheduler of test jobs, processed jobs are sent on
+started and run until their release channel is closed
+*/
+type testScheduler struct {
+	*scheduler
+	started chan string
+	release map[string]chan struct{}
+}
+
+func newTestScheduler(maxJobs int, names ...string) *testScheduler {
+	s := &testScheduler{
+		scheduler: &scheduler{maxJobs: maxJobs, workers: 1},
+		started:   make(chan string, len(names)),
+		release:   map[string]chan struct{}{},
+	}
+	for _, name := range names {
+		s.release[name] = make(chan struct{})
+	}
+	s.process = func(j *job) {
+		s.started <- j.Filename
+		<-s.release[j.Filename]
+	}
+
+	return s
+}
+
+/*
+testJob :: Job of a file, streaming jobs import a pending upload
+*/
+func testJob(name string, priority int, streaming bool) *job {
+	fp := path.Join("/tmp", name)
+	if streaming {
+		fp = path.Join(uploadsDir, name)
+	}
+
+	return startJob(&common.Job{
+		ID:       name,
+		Filename: name,
+		Path:     fp,
+		Priority: priority,
+	})
+}
+
+/*
+expectStarted :: Check that the jobs start, in any order, and that
+no other job starts
+*/
+func (s *testScheduler) expectStarted(t *testing.T, names ...string) {
+	t.Helper()
+	want := map[string]bool{}
+	for _, name := range names {
+		want[name] = true
+	}
+	for range names {
+		select {
+		case started := <-s.started:
+			if !want[started] {
+				t.Fatalf("unexpected start of %s, want %v", started, names)
+			}
+			delete(want, started)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%v not started", want)
+		}
+	}
+	select {
+	case started := <-s.started:
+		t.Fatalf("unexpected start of %s", started)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func (s *testScheduler) slots() (int, int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.running, s.streams
+}
+
+func TestSchedulerPriority(t *testing.T) {
+	s := newTestScheduler(1, "a", "b", "c", "d")
+	jobs := map[string]*job{}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		priority := 0
+		if name == "c" {
+			priority = 5
+		}
+		jobs[name] = testJob(name, priority, false)
+		defer jobs[name].done()
+		s.submit(jobs[name])
+	}
+	s.expectStarted(t, "a")
+
+	/* Higher priority first, same priority by submission */
+	for name, want := range map[string]int{"a": 0, "c": 1, "b": 2, "d": 3} {
+		if got := s.position(jobs[name]); got != want {
+			t.Errorf("%s: position %d, want %d", name, got, want)
+		}
+	}
+	if !s.prioritize(jobs["d"], 10) || s.prioritize(jobs["a"], 10) {
+		t.Error("only queued jobs can be prioritized")
+	}
+
+	close(s.release["a"])
+	s.expectStarted(t, "d")
+	close(s.release["d"])
+	s.expectStarted(t, "c")
+	close(s.release["c"])
+	s.expectStarted(t, "b")
+	close(s.release["b"])
+}
+
+func TestSchedulerSlots(t *testing.T) {
+	s := newTestScheduler(2, "a", "b", "c")
+	for _, name := range []string{"a", "b", "c"} {
+		j := testJob(name, 0, false)
+		defer j.done()
+		s.submit(j)
+	}
+	s.expectStarted(t, "a", "b")
+	if running, _ := s.slots(); running != 2 || len(s.queue) != 1 {
+		t.Fatalf("%d running and %d queued, want 2 and 1", running, len(s.queue))
+	}
+
+	/* A finished job frees its slot */
+	close(s.release["b"])
+	s.expectStarted(t, "c")
+	close(s.release["a"])
+	close(s.release["c"])
+	for i := 0; ; i++ {
+		if running, _ := s.slots(); running == 0 {
+			break
+		}
+		if i > 500 {
+			t.Fatal("slots not released")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSchedulerCancel(t *testing.T) {
+	s := newTestScheduler(1, "a", "b", "c")
+	queued := map[string]*job{}
+	for _, name := range []string{"a", "b", "c"} {
+		queued[name] = testJob(name, 0, false)
+		defer queued[name].done()
+		s.submit(queued[name])
+	}
+	s.expectStarted(t, "a")
+
+	/* Cancelled jobs leave the queue without taking a slot */
+	s.cancel(queued["c"])
+	s.expectStarted(t, "c")
+	if s.position(queued["c"]) != 0 || s.position(queued["b"]) != 1 {
+		t.Errorf("positions after cancel: c %d, b %d", s.position(queued["c"]), s.position(queued["b"]))
+	}
+	close(s.release["c"])
+	if running, _ := s.slots(); running != 1 {
+		t.Errorf("running jobs: got %d, want 1", running)
+	}
+	s.expectStarted(t)
+
+	close(s.release["a"])
+	s.expectStarted(t, "b")
+	close(s.release["b"])
+}
+
+func TestSchedulerStreaming(t *testing.T) {
+	s := newTestScheduler(1, "import", "stream", "queued", "stream2")
+	for _, test := range []struct {
+		name      string
+		streaming bool
+	}{
+		{name: "import"},
+		{name: "stream", streaming: true},
+		{name: "queued"},
+		{name: "stream2", streaming: true},
+	} {
+		j := testJob(test.name, 0, test.streaming)
+		defer j.done()
+		s.submit(j)
+	}
+
+	/* Streaming imports waiting for data do not hold import slots */
+	s.expectStarted(t, "import", "stream")
+	if running, streams := s.slots(); running != 1 || streams != 1 {
+		t.Fatalf("slots: %d imports and %d streams, want 1 and 1", running, streams)
+	}
+	close(s.release["stream"])
+	s.expectStarted(t, "stream2")
+	close(s.release["import"])
+	s.expectStarted(t, "queued")
+	close(s.release["queued"])
+	close(s.release["stream2"])
+}
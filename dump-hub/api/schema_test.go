@@ -0,0 +1,121 @@
+package api
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/x0e1f/dump-hub/common"
+)
+
+func postSchema(t *testing.T, values map[string]string, sample []byte) *httptest.ResponseRecorder {
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	for name, value := range values {
+		form.WriteField(name, value)
+	}
+	part, err := form.CreateFormFile("file", "dump.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(sample)
+	form.WriteField("size", strconv.Itoa(len(sample)))
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/schema", body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	rec := httptest.NewRecorder()
+	schema()(rec, req)
+
+	return rec
+}
+
+func TestSchema(t *testing.T) {
+	insert := "INSERT INTO `users` VALUES (1,'jos\xe9@example.com','pw');\n"
+	utf8Insert := "INSERT INTO `users` VALUES (1,'josé@example.com','pw');\n"
+
+	gzipped := &bytes.Buffer{}
+	gz := gzip.NewWriter(gzipped)
+	gz.Write([]byte(utf8Insert))
+	gz.Close()
+
+	utf16 := []byte{0xff, 0xfe}
+	for _, r := range utf8Insert {
+		utf16 = append(utf16, byte(r), byte(r>>8))
+	}
+
+	tests := []struct {
+		name   string
+		values map[string]string
+		sample []byte
+		table  string
+		email  string
+		status int
+	}{
+		{name: "sql", values: map[string]string{"format": "sql"}, sample: []byte(utf8Insert), table: "users", email: "josé@example.com"},
+		{name: "gzip", values: map[string]string{"format": "sql"}, sample: gzipped.Bytes(), table: "users", email: "josé@example.com"},
+		{name: "utf-16", values: map[string]string{"format": "sql"}, sample: utf16, table: "users", email: "josé@example.com"},
+		{name: "latin-1", values: map[string]string{"format": "sql", "encoding": "iso-8859-1"}, sample: []byte(insert), table: "users", email: "josé@example.com"},
+		{
+			name:   "copy",
+			values: map[string]string{"format": "copy"},
+			sample: []byte("COPY public.users (id, email) FROM stdin;\n1\tjosé@example.com\n\\.\n"),
+			table:  "public.users",
+			email:  "josé@example.com",
+		},
+		{name: "unknown encoding", values: map[string]string{"format": "sql", "encoding": "x"}, sample: []byte(utf8Insert), status: http.StatusBadRequest},
+		{name: "no tables", values: map[string]string{"format": "json"}, sample: []byte(`{"a":1}`), status: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		rec := postSchema(t, test.values, test.sample)
+		if test.status > 0 {
+			if rec.Code != test.status {
+				t.Errorf("%s: got status %d, want %d", test.name, rec.Code, test.status)
+			}
+			continue
+		}
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: got status %d: %s", test.name, rec.Code, rec.Body.String())
+			continue
+		}
+
+		tables := []*common.Table{}
+		json.Unmarshal(rec.Body.Bytes(), &tables)
+		if len(tables) != 1 || tables[0].Name != test.table || len(tables[0].Rows) != 1 || len(tables[0].Rows[0]) < 2 {
+			t.Errorf("%s: got %s", test.name, rec.Body.String())
+			continue
+		}
+		if email := tables[0].Rows[0][1]; email != test.email {
+			t.Errorf("%s: got email %q, want %q", test.name, email, test.email)
+		}
+	}
+}
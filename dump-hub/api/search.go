@@ -33,6 +33,7 @@ import (
 
 type searchReq struct {
 	Query string `json:"query"`
+	Field string `json:"field"`
 	Page  int    `json:"page"`
 }
 
@@ -58,6 +59,7 @@ func search(eClient *elastic.Client) http.HandlerFunc {
 		from := pageSize * (searchReq.Page - 1)
 		results, err := eClient.Search(
 			string(query),
+			searchReq.Field,
 			from,
 			pageSize,
 		)
@@ -56,6 +56,11 @@ func getHistory(eClient *elastic.Client) http.HandlerFunc {
 			http.Error(w, "", http.StatusInternalServerError)
 			return
 		}
+
+		/* Add progress of running imports */
+		for i := range historyData.Results {
+			historyData.Results[i].Progress = importStatus(historyData.Results[i].Checksum)
+		}
 		response, err := json.Marshal(historyData)
 		if err != nil {
 			http.Error(w, "", http.StatusInternalServerError)
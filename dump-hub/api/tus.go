@@ -0,0 +1,727 @@
+package api
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/x0e1f/dump-hub/elastic"
+	"github.com/x0e1f/dump-hub/stream"
+)
+
+/* Supported version of the tus resumable upload protocol */
+const tusVersion = "1.0.0"
+
+/* Supported tus protocol extensions */
+const tusExtensions = "creation,termination,expiration"
+
+/* Folder of pending resumable uploads */
+const uploadsDir = "/tmp/uploads"
+
+/* Pending uploads are removed after this time without changes */
+const uploadExpiration = 24 * time.Hour
+
+/* Wait time of a streaming import for new data */
+const followInterval = 500 * time.Millisecond
+
+/*
+tusUpload :: Pending resumable upload, Metadata holds the file name
+and the upload settings (same names of the upload form values).
+Streaming uploads are imported while they are received
+*/
+type tusUpload struct {
+	ID       string            `json:"id"`
+	Length   int64             `json:"length"`
+	Metadata map[string]string `json:"metadata"`
+	Raw      string            `json:"raw"`
+}
+
+/*
+uploadLocks :: Locks of pending uploads by ID
+*/
+var uploadLocks sync.Map
+
+/*
+lockUpload :: Lock a pending upload, returns the unlock function
+*/
+func lockUpload(id string) func() {
+	lock, _ := uploadLocks.LoadOrStore(id, &sync.Mutex{})
+	mutex := lock.(*sync.Mutex)
+	mutex.Lock()
+
+	return mutex.Unlock
+}
+
+/*
+dataPath :: Path of uploaded data
+*/
+func (u *tusUpload) dataPath() string {
+	return path.Join(uploadsDir, u.ID)
+}
+
+/*
+infoPath :: Path of upload information
+*/
+func (u *tusUpload) infoPath() string {
+	return path.Join(uploadsDir, u.ID+".json")
+}
+
+/*
+hashPath :: Path of the hash state of uploaded data
+*/
+func (u *tusUpload) hashPath() string {
+	return path.Join(uploadsDir, u.ID+".hash")
+}
+
+/*
+streaming :: Check if upload is imported while it is received
+*/
+func (u *tusUpload) streaming() bool {
+	return u.Metadata["stream"] == "true"
+}
+
+/*
+offset :: Bytes received, the size of uploaded data
+*/
+func (u *tusUpload) offset() (int64, error) {
+	info, err := os.Stat(u.dataPath())
+	if err != nil {
+		return 0, err
+	}
+
+	return info.Size(), nil
+}
+
+/*
+expires :: Expiration date of a pending upload (RFC 7231)
+*/
+func (u *tusUpload) expires() string {
+	modTime := time.Now()
+	if info, err := os.Stat(u.dataPath()); err == nil {
+		modTime = info.ModTime()
+	}
+
+	return modTime.Add(uploadExpiration).UTC().Format(http.TimeFormat)
+}
+
+/*
+hasher :: SHA-256 of the first offset bytes of uploaded data, the
+hash state is stored after every request so data is read only once
+*/
+func (u *tusUpload) hasher(offset int64) (hash.Hash, error) {
+	h := sha256.New()
+	state, err := ioutil.ReadFile(u.hashPath())
+	if err == nil && len(state) > 8 && int64(binary.BigEndian.Uint64(state)) == offset {
+		err = h.(encoding.BinaryUnmarshaler).UnmarshalBinary(state[8:])
+		if err == nil {
+			return h, nil
+		}
+	}
+
+	/* Missing or stale state, hash data again */
+	h.Reset()
+	file, err := os.Open(u.dataPath())
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	_, err = io.CopyN(h, file, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+/*
+saveHash :: Store hash state of the first offset bytes
+*/
+func (u *tusUpload) saveHash(h hash.Hash, offset int64) error {
+	state, err := h.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return err
+	}
+	data := make([]byte, 8, 8+len(state))
+	binary.BigEndian.PutUint64(data, uint64(offset))
+
+	return ioutil.WriteFile(u.hashPath(), append(data, state...), 0666)
+}
+
+/*
+finish :: Remove upload information, data is kept
+*/
+func (u *tusUpload) finish() {
+	os.Remove(u.infoPath())
+	os.Remove(u.hashPath())
+	uploadLocks.Delete(u.ID)
+}
+
+/*
+remove :: Remove data and information of an upload
+*/
+func (u *tusUpload) remove() {
+	os.Remove(u.dataPath())
+	u.finish()
+}
+
+/*
+abort :: Remove an incomplete upload, the import of a streaming
+upload is cancelled and rolled back
+*/
+func (u *tusUpload) abort() {
+	if u.streaming() {
+		if j := checksumJob(u.Metadata["checksum"]); j != nil {
+			j.stop(true)
+			imports.cancel(j)
+		}
+	}
+	u.remove()
+}
+
+/*
+loadUpload :: Load pending upload by ID, nil if not found
+*/
+func loadUpload(id string) (*tusUpload, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, nil
+	}
+
+	data, err := ioutil.ReadFile(path.Join(uploadsDir, id+".json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	u := &tusUpload{}
+	err = json.Unmarshal(data, u)
+	if err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+/*
+pendingUpload :: Get incomplete streaming upload of a file path,
+nil if the file is not a pending upload
+*/
+func pendingUpload(filePath string) *tusUpload {
+	if path.Dir(filePath) != uploadsDir {
+		return nil
+	}
+
+	u, err := loadUpload(path.Base(filePath))
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	return u
+}
+
+/*
+parseMetadata :: Parse Upload-Metadata header, comma separated
+key and base64 value pairs
+*/
+func parseMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		switch len(fields) {
+		case 0:
+			continue
+		case 1:
+			metadata[fields[0]] = ""
+		case 2:
+			value, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid metadata value: %s", fields[0])
+			}
+			metadata[fields[0]] = string(value)
+		default:
+			return nil, fmt.Errorf("invalid metadata: %s", pair)
+		}
+	}
+
+	return metadata, nil
+}
+
+/*
+tusHeaders :: Set protocol headers, false if the client protocol
+version is not supported (412)
+*/
+func tusHeaders(w http.ResponseWriter, r *http.Request) bool {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	if r.Method != http.MethodOptions && r.Header.Get("Tus-Resumable") != tusVersion {
+		w.Header().Set("Tus-Version", tusVersion)
+		http.Error(w, "unsupported protocol version", http.StatusPreconditionFailed)
+		return false
+	}
+
+	return true
+}
+
+/*
+tusOptions :: Describe server protocol support (OPTIONS)
+*/
+func tusOptions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tusHeaders(w, r)
+		w.Header().Set("Tus-Version", tusVersion)
+		w.Header().Set("Tus-Extension", tusExtensions)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+/*
+createUpload :: Create a resumable upload (POST), settings are
+validated and duplicates of a declared checksum are rejected before
+any data is sent. Streaming uploads are queued for import right away
+*/
+func createUpload(eClient *elastic.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !tusHeaders(w, r) {
+			return
+		}
+
+		length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+		if err != nil || length < 1 {
+			http.Error(w, "invalid upload length", http.StatusBadRequest)
+			return
+		}
+		raw := r.Header.Get("Upload-Metadata")
+		metadata, err := parseMetadata(raw)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		settings, err := readSettings(func(name string) string {
+			return metadata[name]
+		})
+		if err != nil {
+			log.Printf("(ERROR) Parser creation error: (%s)", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		/* Duplicated files are rejected before receiving them */
+		checkSum := metadata["checksum"]
+		if len(checkSum) > 0 {
+			fileExist, err := eClient.IsAlreadyUploaded(checkSum)
+			if err != nil {
+				log.Println(err)
+				http.Error(w, "", http.StatusInternalServerError)
+				return
+			}
+			if fileExist {
+				log.Println("File already exist: ", checkSum)
+				http.Error(w, "", http.StatusTeapot)
+				return
+			}
+		}
+
+		/* Create empty data file and store upload information */
+		u := &tusUpload{
+			ID:       uuid.New().String(),
+			Length:   length,
+			Metadata: metadata,
+			Raw:      raw,
+		}
+		if u.streaming() && len(checkSum) < 1 {
+			http.Error(w, "streaming uploads require a checksum", http.StatusBadRequest)
+			return
+		}
+		err = os.MkdirAll(uploadsDir, 0755)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		data, err := json.Marshal(u)
+		if err == nil {
+			err = ioutil.WriteFile(u.infoPath(), data, 0666)
+		}
+		if err == nil {
+			err = ioutil.WriteFile(u.dataPath(), nil, 0666)
+		}
+		if err != nil {
+			log.Println(err)
+			u.remove()
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+
+		/* Streaming imports follow the data file */
+		if u.streaming() {
+			j, err := queueStream(eClient, settings, u)
+			if err != nil {
+				log.Println(err)
+				u.remove()
+				http.Error(w, "", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Upload-Job", j.ID)
+		}
+
+		w.Header().Set("Location", path.Join(r.URL.Path, u.ID))
+		w.Header().Set("Upload-Expires", u.expires())
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+/*
+queueStream :: Create history document and queue import of a
+streaming upload
+*/
+func queueStream(e *elastic.Client, settings *uploadSettings, u *tusUpload) (*job, error) {
+	return queueHistory(e, settings, u.filename(), u.dataPath(), u.Metadata["checksum"])
+}
+
+/*
+filename :: Name of the uploaded file
+*/
+func (u *tusUpload) filename() string {
+	if filename := u.Metadata["filename"]; len(filename) > 0 {
+		return filename
+	}
+
+	return u.ID
+}
+
+/*
+uploadOffset :: Get offset of a resumable upload (HEAD)
+*/
+func uploadOffset() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !tusHeaders(w, r) {
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+
+		u, err := loadUpload(mux.Vars(r)["id"])
+		if err != nil {
+			log.Println(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if u == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		offset, err := u.offset()
+		if err != nil {
+			log.Println(err)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+		w.Header().Set("Upload-Length", strconv.FormatInt(u.Length, 10))
+		w.Header().Set("Upload-Expires", u.expires())
+		if len(u.Raw) > 0 {
+			w.Header().Set("Upload-Metadata", u.Raw)
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+/*
+patchUpload :: Append data to a resumable upload (PATCH), data is
+hashed while it is written. Once every byte is received the file
+is imported like a form upload and the job ID is returned in the
+Upload-Job header
+*/
+func patchUpload(eClient *elastic.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !tusHeaders(w, r) {
+			return
+		}
+		if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+			http.Error(w, "invalid content type", http.StatusUnsupportedMediaType)
+			return
+		}
+
+		u, err := loadUpload(mux.Vars(r)["id"])
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		if u == nil {
+			http.Error(w, "upload not found", http.StatusNotFound)
+			return
+		}
+		unlock := lockUpload(u.ID)
+		defer unlock()
+
+		/* Data must be sent from the current offset */
+		offset, err := u.offset()
+		if err != nil {
+			http.Error(w, "upload not found", http.StatusNotFound)
+			return
+		}
+		requested, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+		if err != nil || requested != offset {
+			w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+			http.Error(w, "offset mismatch", http.StatusConflict)
+			return
+		}
+
+		/* Append and hash data, bytes received before an interruption are kept */
+		h, err := u.hasher(offset)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		file, err := os.OpenFile(u.dataPath(), os.O_WRONLY|os.O_APPEND, 0666)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		n, err := io.Copy(file, io.TeeReader(io.LimitReader(r.Body, u.Length-offset), h))
+		file.Close()
+		offset += n
+		if hErr := u.saveHash(h, offset); hErr != nil {
+			log.Println(hErr)
+		}
+		w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+		if err != nil {
+			log.Printf("(ERROR) (%s) Upload interrupted at %d bytes: %s", u.ID, offset, err)
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		if offset < u.Length {
+			w.Header().Set("Upload-Expires", u.expires())
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		/* Upload complete, import file */
+		checkSum := hex.EncodeToString(h.Sum(nil))
+		if u.streaming() {
+			err = finishStream(u, checkSum)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if declared := u.Metadata["checksum"]; len(declared) > 0 && declared != checkSum {
+			u.remove()
+			http.Error(w, "checksum mismatch", http.StatusBadRequest)
+			return
+		}
+		settings, err := readSettings(func(name string) string {
+			return u.Metadata[name]
+		})
+		if err != nil {
+			u.remove()
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filePath := "/tmp/" + u.ID
+		err = os.Rename(u.dataPath(), filePath)
+		u.remove()
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+
+		j, err := submitUpload(eClient, settings, u.filename(), filePath, checkSum)
+		if err == nil {
+			w.Header().Set("Upload-Job", j.ID)
+		}
+		if err == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeJob(w, j, err)
+	}
+}
+
+/*
+finishStream :: Complete a streaming upload, the import is rolled
+back if the data does not match the declared checksum or if the
+file is an archive
+*/
+func finishStream(u *tusUpload, checkSum string) error {
+	var err error
+	switch isArchive, aErr := stream.IsArchive(u.dataPath()); {
+	case checkSum != u.Metadata["checksum"]:
+		err = errors.New("checksum mismatch")
+	case aErr == nil && isArchive:
+		err = errors.New("archives can not be streamed")
+	}
+	if err != nil {
+		log.Printf("(ERROR) (%s) %s", u.filename(), err)
+		u.abort()
+		return err
+	}
+
+	/* The import job removes data once finished */
+	u.finish()
+
+	return nil
+}
+
+/*
+terminateUpload :: Delete a pending upload (DELETE)
+*/
+func terminateUpload() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !tusHeaders(w, r) {
+			return
+		}
+
+		u, err := loadUpload(mux.Vars(r)["id"])
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		if u == nil {
+			http.Error(w, "upload not found", http.StatusNotFound)
+			return
+		}
+		unlock := lockUpload(u.ID)
+		u.abort()
+		unlock()
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+/*
+expireUploads :: Periodically remove expired pending uploads
+*/
+func expireUploads() {
+	for {
+		removeExpired()
+		time.Sleep(time.Hour)
+	}
+}
+
+/*
+removeExpired :: Remove pending uploads not changed for
+uploadExpiration
+*/
+func removeExpired() {
+	dir, err := ioutil.ReadDir(uploadsDir)
+	if err != nil && !os.IsNotExist(err) {
+		log.Println(err)
+	}
+	for _, d := range dir {
+		id := strings.TrimSuffix(d.Name(), ".json")
+		if id == d.Name() {
+			continue
+		}
+		u, err := loadUpload(id)
+		if err != nil || u == nil {
+			continue
+		}
+		info, err := os.Stat(u.dataPath())
+		if err == nil && time.Since(info.ModTime()) < uploadExpiration {
+			continue
+		}
+
+		log.Printf("Removing expired upload %s", u.ID)
+		unlock := lockUpload(u.ID)
+		u.abort()
+		unlock()
+	}
+}
+
+/*
+follow :: Read data of a streaming upload as it is received, the
+reader waits for new data until the upload is complete
+*/
+func (u *tusUpload) follow(ctx context.Context, r io.Reader) io.Reader {
+	return &uploadReader{
+		reader: r,
+		upload: u,
+		ctx:    ctx,
+	}
+}
+
+/*
+uploadReader :: Reader of a streaming upload
+*/
+type uploadReader struct {
+	reader io.Reader
+	upload *tusUpload
+	ctx    context.Context
+	read   int64
+}
+
+func (r *uploadReader) Read(b []byte) (int, error) {
+	for {
+		n, err := r.reader.Read(b)
+		r.read += int64(n)
+		if n > 0 || err != io.EOF || r.read >= r.upload.Length {
+			return n, err
+		}
+
+		/* Wait for data, the upload information is removed once complete */
+		_, statErr := os.Stat(r.upload.infoPath())
+		if os.IsNotExist(statErr) {
+			n, err = r.reader.Read(b)
+			r.read += int64(n)
+			if n > 0 || r.read >= r.upload.Length {
+				return n, err
+			}
+			return 0, errors.New("upload aborted")
+		}
+		select {
+		case <-r.ctx.Done():
+			return 0, r.ctx.Err()
+		case <-time.After(followInterval):
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package api
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/x0e1f/dump-hub/common"
+)
+
+/*
+tusRequest :: Send a tus protocol request
+*/
+func tusRequest(t *testing.T, method string, url string, headers map[string]string, body []byte) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Tus-Resumable", tusVersion)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	return resp
+}
+
+/*
+tusMetadata :: Encode Upload-Metadata header
+*/
+func tusMetadata(metadata map[string]string) string {
+	pairs := []string{}
+	for key, value := range metadata {
+		pairs = append(pairs, key+" "+base64.StdEncoding.EncodeToString([]byte(value)))
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+/*
+createTus :: Create a resumable upload, returns its URL
+*/
+func createTus(t *testing.T, url string, length int, metadata map[string]string) *http.Response {
+	t.Helper()
+	return tusRequest(t, http.MethodPost, url+"/api/uploads", map[string]string{
+		"Upload-Length":   strconv.Itoa(length),
+		"Upload-Metadata": tusMetadata(metadata),
+	}, nil)
+}
+
+/*
+patchTus :: Send upload data from offset
+*/
+func patchTus(t *testing.T, url string, offset int, data []byte) *http.Response {
+	t.Helper()
+	return tusRequest(t, http.MethodPatch, url, map[string]string{
+		"Content-Type":  "application/offset+octet-stream",
+		"Upload-Offset": strconv.Itoa(offset),
+	}, data)
+}
+
+func TestAbortStreamingUpload(t *testing.T) {
+	node, e := newTestNode(t)
+	imports = newScheduler(e, 1, 1)
+	server := newTestEngine(t, e)
+	client := events.subscribe()
+	defer events.unsubscribe(client)
+
+	metadata := map[string]string{
+		"filename": "stream.txt",
+		"checksum": "stream",
+		"stream":   "true",
+		"pattern":  "{:}{#}",
+		"columns":  "0:email:email,1:password:password",
+	}
+	for i := 0; i < 2; i++ {
+		resp := createTus(t, server.URL, 1024*1024, metadata)
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("create %d: got %d", i, resp.StatusCode)
+		}
+		location := server.URL + resp.Header.Get("Location")
+		j := findJob(resp.Header.Get("Upload-Job"))
+		if j == nil {
+			t.Fatalf("create %d: streaming job not registered", i)
+		}
+
+		/* Less data than the encoding sample keeps the import waiting */
+		resp = patchTus(t, location, 0, []byte("user@example.com:password\n"))
+		if resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("patch %d: got %d", i, resp.StatusCode)
+		}
+		waitEvent(t, client, "stream", common.EventStarted)
+
+		/* Terminated upload rolls back the import so it can be sent again */
+		resp = tusRequest(t, http.MethodDelete, location, nil, nil)
+		if resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("terminate %d: got %d", i, resp.StatusCode)
+		}
+		waitEvent(t, client, "stream", common.EventDeleted)
+		waitUnregistered(t, j)
+		if node.doc("dump-hub-history", "stream") != nil {
+			t.Fatalf("terminate %d: history not deleted", i)
+		}
+	}
+}
+
+/*
+failingReader :: Request body of an interrupted connection
+*/
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+/*
+tusData :: Combolist sent by tus tests and its checksum
+*/
+func tusData(n int) ([]byte, string) {
+	lines := []string{}
+	for i := 0; i < n; i++ {
+		lines = append(lines, fmt.Sprintf("user%d@example.com:pass%d", i, i))
+	}
+	data := []byte(strings.Join(lines, "\n"))
+	hash := sha256.Sum256(data)
+
+	return data, hex.EncodeToString(hash[:])
+}
+
+func TestTusProtocol(t *testing.T) {
+	node, e := newTestNode(t)
+	imports = newScheduler(e, 1, 1)
+	server := newTestEngine(t, e)
+	client := events.subscribe()
+	defer events.unsubscribe(client)
+
+	resp := tusRequest(t, http.MethodOptions, server.URL+"/api/uploads", nil, nil)
+	if resp.StatusCode != http.StatusNoContent || resp.Header.Get("Tus-Version") != tusVersion ||
+		!strings.Contains(resp.Header.Get("Tus-Extension"), "termination") {
+		t.Errorf("options: got %d %v", resp.StatusCode, resp.Header)
+	}
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/api/uploads", nil)
+	req.Header.Set("Upload-Length", "10")
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("create without protocol version: got %v %v", resp, err)
+	}
+
+	data, checkSum := tusData(200)
+	metadata := map[string]string{
+		"filename": "tus.txt",
+		"checksum": checkSum,
+		"pattern":  "{:}{#}",
+		"columns":  "0:email:email,1:password:password",
+	}
+	resp = createTus(t, server.URL, len(data), metadata)
+	if resp.StatusCode != http.StatusCreated || len(resp.Header.Get("Upload-Expires")) < 1 {
+		t.Fatalf("create: got %d %v", resp.StatusCode, resp.Header)
+	}
+	location := server.URL + resp.Header.Get("Location")
+
+	/* First chunk */
+	resp = patchTus(t, location, 0, data[:1000])
+	if resp.StatusCode != http.StatusNoContent || resp.Header.Get("Upload-Offset") != "1000" {
+		t.Fatalf("first chunk: got %d, offset %s", resp.StatusCode, resp.Header.Get("Upload-Offset"))
+	}
+
+	/* Interrupted chunk keeps the bytes received */
+	req = httptest.NewRequest(http.MethodPatch, resp.Request.URL.Path, io.MultiReader(bytes.NewReader(data[1000:1500]), failingReader{}))
+	req.Header.Set("Tus-Resumable", tusVersion)
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", "1000")
+	w := httptest.NewRecorder()
+	server.Config.Handler.ServeHTTP(w, req)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("interrupted chunk: got %d", w.Code)
+	}
+
+	/* Client resumes from the offset known to the server */
+	resp = tusRequest(t, http.MethodHead, location, nil, nil)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Upload-Offset") != "1500" ||
+		resp.Header.Get("Upload-Length") != strconv.Itoa(len(data)) || len(resp.Header.Get("Upload-Metadata")) < 1 {
+		t.Fatalf("head: got %d %v", resp.StatusCode, resp.Header)
+	}
+	resp = patchTus(t, location, 1000, data[1000:])
+	if resp.StatusCode != http.StatusConflict || resp.Header.Get("Upload-Offset") != "1500" {
+		t.Errorf("offset mismatch: got %d, offset %s", resp.StatusCode, resp.Header.Get("Upload-Offset"))
+	}
+	resp = patchTus(t, location, 1500, data[1500:3000])
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("third chunk: got %d", resp.StatusCode)
+	}
+
+	/* Last chunk matches the declared checksum and queues the import */
+	resp = patchTus(t, location, 3000, data[3000:])
+	if resp.StatusCode != http.StatusNoContent || len(resp.Header.Get("Upload-Job")) < 1 {
+		t.Fatalf("last chunk: got %d %v", resp.StatusCode, resp.Header)
+	}
+	waitEvent(t, client, checkSum, common.EventCompleted)
+	if count := node.entries(checkSum); count != 200 {
+		t.Errorf("indexed entries: got %d, want 200", count)
+	}
+	if resp = tusRequest(t, http.MethodHead, location, nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("head of a finished upload: got %d", resp.StatusCode)
+	}
+
+	/* Duplicates of an imported file are rejected on creation */
+	if resp = createTus(t, server.URL, len(data), metadata); resp.StatusCode != http.StatusTeapot {
+		t.Errorf("duplicate: got %d, want %d", resp.StatusCode, http.StatusTeapot)
+	}
+}
+
+func TestTusChecksumMismatch(t *testing.T) {
+	node, e := newTestNode(t)
+	imports = newScheduler(e, 1, 1)
+	server := newTestEngine(t, e)
+
+	data, _ := tusData(50)
+	resp := createTus(t, server.URL, len(data), map[string]string{
+		"filename": "mismatch.txt",
+		"checksum": "0123",
+		"pattern":  "{:}{#}",
+		"columns":  "0,1",
+	})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create: got %d", resp.StatusCode)
+	}
+	location := server.URL + resp.Header.Get("Location")
+	resp = patchTus(t, location, 0, data)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("checksum mismatch: got %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+	if resp = tusRequest(t, http.MethodHead, location, nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("head of a rejected upload: got %d", resp.StatusCode)
+	}
+	if node.count("dump-hub-history") > 0 {
+		t.Error("history of a rejected upload")
+	}
+}
+
+func TestTusTerminate(t *testing.T) {
+	_, e := newTestNode(t)
+	server := newTestEngine(t, e)
+
+	data, _ := tusData(50)
+	resp := createTus(t, server.URL, len(data), map[string]string{"pattern": "{:}{#}", "columns": "0,1"})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create: got %d", resp.StatusCode)
+	}
+	location := server.URL + resp.Header.Get("Location")
+	patchTus(t, location, 0, data[:100])
+
+	if resp = tusRequest(t, http.MethodDelete, location, nil, nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("terminate: got %d", resp.StatusCode)
+	}
+	if resp = tusRequest(t, http.MethodHead, location, nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("head of a terminated upload: got %d", resp.StatusCode)
+	}
+	if resp = patchTus(t, location, 100, data[100:]); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("patch of a terminated upload: got %d", resp.StatusCode)
+	}
+	if resp = tusRequest(t, http.MethodDelete, location, nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("terminate twice: got %d", resp.StatusCode)
+	}
+}
+
+func TestTusExpiration(t *testing.T) {
+	_, e := newTestNode(t)
+	server := newTestEngine(t, e)
+
+	locations := []string{}
+	for i := 0; i < 2; i++ {
+		resp := createTus(t, server.URL, 100, map[string]string{"pattern": "{:}{#}", "columns": "0,1"})
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("create %d: got %d", i, resp.StatusCode)
+		}
+		locations = append(locations, server.URL+resp.Header.Get("Location"))
+	}
+
+	/* Only the upload not changed for uploadExpiration is removed */
+	u, err := loadUpload(path.Base(locations[0]))
+	if err != nil || u == nil {
+		t.Fatalf("upload not found: %v", err)
+	}
+	old := time.Now().Add(-uploadExpiration - time.Minute)
+	if err := os.Chtimes(u.dataPath(), old, old); err != nil {
+		t.Fatal(err)
+	}
+	removeExpired()
+
+	for i, want := range []int{http.StatusNotFound, http.StatusOK} {
+		if resp := tusRequest(t, http.MethodHead, locations[i], nil, nil); resp.StatusCode != want {
+			t.Errorf("upload %d: got %d, want %d", i, resp.StatusCode, want)
+		}
+	}
+	tusRequest(t, http.MethodDelete, locations[1], nil, nil)
+}
@@ -24,224 +24,618 @@ OTHER DEALINGS IN THE SOFTWARE.
 */
 
 import (
-	"bufio"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
-	"runtime"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/x0e1f/dump-hub/common"
 	"github.com/x0e1f/dump-hub/elastic"
 	"github.com/x0e1f/dump-hub/parser"
+	"github.com/x0e1f/dump-hub/stream"
 )
 
-const chunkSize = 1000
+/* Minimum time between checkpoints of an import */
+const checkpointInterval = 10 * time.Second
 
 /*
-upload :: Upload dump file (POST)
+errDuplicate :: File already uploaded
 */
-func upload(eClient *elastic.Client) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		r.ParseMultipartForm(1024 * 1024)
+var errDuplicate = errors.New("file already exist")
 
-		/* Get Pattern Value */
-		pattern := r.FormValue("pattern")
-		if len(pattern) <= 0 {
-			log.Printf("(ERROR) (%s) pattern value not found", r.URL)
-			http.Error(w, "", http.StatusBadRequest)
-			return
-		}
-		/* Get Columns Value */
-		columns := r.FormValue("columns")
-		if len(columns) <= 0 {
-			log.Printf("(ERROR) (%s) columns value not found", r.URL)
-			http.Error(w, "", http.StatusBadRequest)
-		}
+/*
+uploadSettings :: Parser settings, scheduling and tags of an upload,
+source is one of the import sources
+*/
+type uploadSettings struct {
+	config   *common.ParserConfig
+	members  map[string]*common.ParserConfig
+	priority int
+	workers  int
+	tags     []string
+	source   string
+}
 
-		p, err := parser.New(pattern, columns)
-		if err != nil {
-			log.Printf("(ERROR) Parser creation error: (%s)", err)
-			http.Error(w, "", http.StatusBadRequest)
-			return
-		}
+/* Maximum size of an upload form value */
+const maxValueSize = 1024 * 1024
 
-		/* Get File Body */
-		file, handler, err := r.FormFile("file")
+/*
+upload :: Upload dump file (POST), the file is hashed while it is
+written on tmp. Settings are form values sent before the file and
+are validated before reading it, a checksum value sent before the
+file also rejects duplicated files
+*/
+func upload(eClient *elastic.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reader, err := r.MultipartReader()
 		if err != nil {
 			log.Printf("(ERROR) (%s) %s", r.URL, err)
 			http.Error(w, "", http.StatusBadRequest)
 			return
 		}
-		defer file.Close()
 
-		/* Check Content-Type */
-		contentType := handler.Header["Content-Type"][0]
-		if !strings.Contains(contentType, "text/") {
-			log.Printf("(ERROR) (%s) invalid content type.", r.URL)
-			http.Error(w, "", http.StatusBadRequest)
+		/* Read form values and stream file on tmp */
+		values := map[string]string{}
+		var settings *uploadSettings
+		var filename, filePath, checkSum string
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				log.Printf("(ERROR) (%s) %s", r.URL, err)
+				removeUpload(filePath)
+				http.Error(w, "", http.StatusBadRequest)
+				return
+			}
+
+			if part.FormName() != "file" {
+				value, err := ioutil.ReadAll(io.LimitReader(part, maxValueSize))
+				if err != nil {
+					log.Printf("(ERROR) (%s) %s", r.URL, err)
+					removeUpload(filePath)
+					http.Error(w, "", http.StatusBadRequest)
+					return
+				}
+				values[part.FormName()] = string(value)
+				continue
+			}
+			if len(filePath) > 0 {
+				continue
+			}
+
+			/* Settings are validated before reading the file */
+			settings, err = readSettings(func(name string) string {
+				return values[name]
+			})
+			if err != nil {
+				log.Printf("(ERROR) Parser creation error: (%s)", err)
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			/* Duplicated files are rejected before reading them */
+			if declared := values["checksum"]; len(declared) > 0 {
+				fileExist, err := eClient.IsAlreadyUploaded(declared)
+				if err != nil {
+					log.Println(err)
+					http.Error(w, "", http.StatusInternalServerError)
+					return
+				}
+				if fileExist {
+					log.Println("File already exist: ", declared)
+					http.Error(w, "", http.StatusTeapot)
+					return
+				}
+			}
+
+			filename = part.FileName()
+			filePath, checkSum, err = writeUpload(part)
+			if err != nil {
+				log.Println(err)
+				removeUpload(filePath)
+				http.Error(w, "", http.StatusInternalServerError)
+				return
+			}
+		}
+		if len(filePath) < 1 {
+			http.Error(w, "missing file", http.StatusBadRequest)
 			return
 		}
 
-		/* Upload file on tmp */
-		filePath := "/tmp/" + uuid.New().String()
-		tmpFile, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE, 0666)
-		if err != nil {
-			log.Println(err)
-			http.Error(w, "", http.StatusInternalServerError)
+		if declared := values["checksum"]; len(declared) > 0 && declared != checkSum {
+			removeUpload(filePath)
+			http.Error(w, "checksum mismatch", http.StatusBadRequest)
 			return
 		}
-		defer tmpFile.Close()
-		io.Copy(tmpFile, file)
 
-		/* Compute file checksum */
-		f, err := os.Open(filePath)
-		if err != nil {
-			log.Println(err)
-			http.Error(w, "", http.StatusInternalServerError)
-			return
+		j, err := submitUpload(eClient, settings, filename, filePath, checkSum)
+		writeJob(w, j, err)
+	}
+}
+
+/*
+writeUpload :: Write uploaded file on tmp and compute its checksum
+in a single pass, returns file path and checksum
+*/
+func writeUpload(r io.Reader) (string, string, error) {
+	filePath := "/tmp/" + uuid.New().String()
+	tmpFile, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE, 0666)
+	if err != nil {
+		return "", "", err
+	}
+	defer tmpFile.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(tmpFile, io.TeeReader(r, hash))
+	if err != nil {
+		return filePath, "", err
+	}
+
+	return filePath, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+/*
+fileChecksum :: Compute SHA-256 checksum of a file
+*/
+func fileChecksum(fp string) (string, error) {
+	f, err := os.Open(fp)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, f)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+/*
+removeUpload :: Remove uploaded file if it was written
+*/
+func removeUpload(filePath string) {
+	if len(filePath) > 0 {
+		os.Remove(filePath)
+	}
+}
+
+/*
+readSettings :: Read and validate upload settings from named values
+*/
+func readSettings(value func(string) string) (*uploadSettings, error) {
+	config, err := parserConfig(value)
+	if err != nil {
+		return nil, err
+	}
+	_, err = newParser(config)
+	if err != nil {
+		return nil, err
+	}
+
+	/* Parser settings of archive members */
+	members, err := memberConfigs(value("members"))
+	if err != nil {
+		return nil, err
+	}
+
+	/* Scheduling of the import */
+	priority, workers, err := jobOptions(value)
+	if err != nil {
+		return nil, err
+	}
+
+	/* Comma separated history tags */
+	tags := []string{}
+	for _, tag := range strings.Split(value("tags"), ",") {
+		if tag = strings.TrimSpace(tag); len(tag) > 0 {
+			tags = append(tags, tag)
 		}
-		hash := sha256.New()
-		if _, err := io.Copy(hash, f); err != nil {
-			log.Println(err)
-			http.Error(w, "", http.StatusInternalServerError)
-			return
+	}
+
+	return &uploadSettings{
+		config:   config,
+		members:  members,
+		priority: priority,
+		workers:  workers,
+		tags:     tags,
+		source:   common.SourceUpload,
+	}, nil
+}
+
+/*
+jsonValue :: Read upload settings from a JSON object with the names of
+the upload form values, lists are joined by commas and objects are
+kept as JSON
+*/
+func jsonValue(values map[string]interface{}) func(string) string {
+	return func(name string) string {
+		switch value := values[name].(type) {
+		case nil:
+			return ""
+		case string:
+			return value
+		case float64:
+			return strconv.FormatFloat(value, 'f', -1, 64)
+		case []interface{}:
+			items := []string{}
+			for _, item := range value {
+				items = append(items, fmt.Sprint(item))
+			}
+			return strings.Join(items, ",")
+		default:
+			data, _ := json.Marshal(value)
+			return string(data)
 		}
-		checkSum := hex.EncodeToString(hash.Sum(nil))
+	}
+}
 
-		/* Check if file already exist */
-		fileExist, err := eClient.IsAlreadyUploaded(checkSum)
+/*
+submitUpload :: Queue import of an uploaded file and its checksum,
+archives are extracted in background by an extraction job. Returns
+errDuplicate if the file was already uploaded
+*/
+func submitUpload(e *elastic.Client, settings *uploadSettings, fn string, fp string, checkSum string) (*common.Job, error) {
+	/* Archives are processed member by member */
+	isArchive, err := stream.IsArchive(fp)
+	if err != nil {
+		return nil, err
+	}
+	if isArchive {
+		return queueArchive(e, settings, fn, fp, checkSum), nil
+	}
+
+	/* Check if file already exist */
+	fileExist, err := e.IsAlreadyUploaded(checkSum)
+	if err != nil {
+		return nil, err
+	}
+	if fileExist {
+		log.Println("File already exist: ", checkSum)
+		releaseFile(settings.source, fp, false)
+		return nil, errDuplicate
+	}
+
+	j, err := queueHistory(e, settings, fn, fp, checkSum)
+	if err != nil {
+		return nil, err
+	}
+
+	return j.Job, nil
+}
+
+/*
+queueHistory :: Create history document of a file and queue its
+import job with the source and parser settings of the upload
+*/
+func queueHistory(e *elastic.Client, settings *uploadSettings, fn string, fp string, checkSum string) (*job, error) {
+	history := common.History{
+		Date:     time.Now().Format("2006-01-02 15:04:05"),
+		Filename: fn,
+		Checksum: checkSum,
+		Status:   common.StatusProcessing,
+		Tags:     settings.tags,
+	}
+	err := e.NewHistory(&history, checkSum)
+	if err != nil {
+		return nil, err
+	}
+
+	j := newJob(checkSum, fn, fp, settings)
+	queueJob(e, j)
+
+	return j, nil
+}
+
+/*
+writeJob :: Write queued job or upload error, duplicated files
+are rejected with 418
+*/
+func writeJob(w http.ResponseWriter, j *common.Job, err error) {
+	if err == errDuplicate {
+		http.Error(w, "", http.StatusTeapot)
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	response, err := json.Marshal(j)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
+
+/*
+parserConfig :: Read parser settings from named values
+*/
+func parserConfig(value func(string) string) (*common.ParserConfig, error) {
+	maxLine := 0
+	if v := value("max_line"); len(v) > 0 {
+		var err error
+		maxLine, err = strconv.Atoi(v)
 		if err != nil {
-			log.Println(err)
-			http.Error(w, "", http.StatusInternalServerError)
-			return
+			return nil, fmt.Errorf("invalid max line length: %s", v)
 		}
-		if fileExist {
-			log.Println("File already exist: ", checkSum)
-			http.Error(w, "", http.StatusTeapot)
-			return
+	}
+
+	header := false
+	if v := value("header"); len(v) > 0 {
+		var err error
+		header, err = strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid header value: %s", v)
 		}
+	}
 
-		/* Create history document */
-		date := time.Now().Format("2006-01-02 15:04:05")
-		history := common.History{
-			Date:     date,
-			Filename: handler.Filename,
-			Checksum: checkSum,
-			Status:   0,
+	return &common.ParserConfig{
+		Format:    value("format"),
+		Pattern:   value("pattern"),
+		Columns:   value("columns"),
+		Quote:     value("quote"),
+		Escape:    value("escape"),
+		Regex:     value("regex"),
+		Table:     value("table"),
+		Encoding:  value("encoding"),
+		MaxLine:   maxLine,
+		LongLines: value("long_lines"),
+		Header:    header,
+	}, nil
+}
+
+/*
+jobOptions :: Read import priority and uploader routines from named
+values, both default to 0
+*/
+func jobOptions(value func(string) string) (int, int, error) {
+	values := []int{0, 0}
+	for i, name := range []string{"priority", "workers"} {
+		v := value(name)
+		if len(v) < 1 {
+			continue
 		}
-		err = eClient.NewHistory(&history, checkSum)
+		n, err := strconv.Atoi(v)
 		if err != nil {
-			log.Println(err)
-			http.Error(w, "", http.StatusInternalServerError)
-			return
+			return 0, 0, fmt.Errorf("invalid %s: %s", name, v)
 		}
+		values[i] = n
+	}
+	if values[1] < 0 || values[1] > maxWorkers {
+		return 0, 0, fmt.Errorf("invalid workers: %d (max %d)", values[1], maxWorkers)
+	}
 
-		/* Process file in background */
-		go processFile(
-			eClient,
-			p,
-			handler.Filename,
-			filePath,
-			checkSum,
-		)
+	return values[0], values[1], nil
+}
 
-		w.WriteHeader(http.StatusOK)
+/*
+newParser :: Validate input encoding and create parser
+*/
+func newParser(config *common.ParserConfig) (*parser.Parser, error) {
+	if len(config.Encoding) > 0 && !stream.IsEncoding(config.Encoding) {
+		return nil, fmt.Errorf("unsupported encoding: %s", config.Encoding)
 	}
+
+	return parser.New(config)
 }
 
 /*
-processFile :: Process file line by line
+processFile :: Process file entries of an import job, the stored
+job is removed once the import is finished
 */
-func processFile(e *elastic.Client, p *parser.Parser, fn string, fp string, cs string) {
-	/* Open file from tmp */
-	file, err := os.Open(fp)
-	if err != nil {
-		e.UpdateHistoryStatus(cs, -1)
-		log.Println(err)
-	}
+func processFile(e *elastic.Client, j *job) {
+	defer j.done()
 	defer func() {
-		file.Close()
-		err := os.Remove(fp)
+		err := e.DeleteJob(j.ID)
 		if err != nil {
 			log.Println(err)
 		}
 	}()
+	defer func() {
+		releaseFile(j.Source, j.Path, j.imported())
+	}()
+	cs, fn := j.Checksum, j.Filename
 
-	/* Start uploader routines */
-	var wg sync.WaitGroup
-	quitChan := make(chan struct{})
-	entryChan := make(chan *common.Entry)
-	for i := 0; i < runtime.GOMAXPROCS(0); i++ {
-		go uploader(i, &wg, e, quitChan, entryChan)
+	p, err := newParser(j.Config)
+	if err != nil {
+		log.Println(err)
+		finishImport(e, j, common.StatusFailed, nil)
+		return
 	}
 
-	/* Scan file line by line */
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		/* Parse entry document */
-		entry := p.ParseEntry(fn, cs, scanner.Text())
-		if entry == nil {
-			continue
-		}
-		entryChan <- entry
+	/* Open file from tmp */
+	file, err := os.Open(j.Path)
+	if err != nil {
+		log.Println(err)
+		finishImport(e, j, common.StatusFailed, nil)
+		return
+	}
+	defer file.Close()
+
+	/* Job cancelled while queued */
+	if stopped(e, j) {
+		return
+	}
+
+	/* Track import progress */
+	var size int64
+	if info, err := file.Stat(); err == nil {
+		size = info.Size()
 	}
 
-	close(quitChan)
-	close(entryChan)
+	/* Streaming uploads are read while they are received */
+	var source io.Reader = file
+	if u := pendingUpload(j.Path); u != nil {
+		size = u.Length
+		source = u.follow(j.ctx, file)
+	}
+	j.progress.begin(size)
+	events.publish(common.EventStarted, cs, fn, common.StatusProcessing, nil)
+	done := make(chan struct{})
+	defer close(done)
+	go publishProgress(j, done)
 
-	wg.Wait()
-	log.Printf("Processing complete: %s", fn)
+	/* Decompress file on the fly */
+	reader, compression, err := stream.Decompress(j.reader(source))
+	if err != nil {
+		failImport(e, j, err)
+		return
+	}
+	defer reader.Close()
+	if len(compression) > 0 {
+		log.Printf("Decompressing %s (%s)", fn, compression)
+	}
+
+	/* Convert entries to UTF-8 */
+	text, enc, err := stream.Transcode(reader, j.Config.Encoding)
+	if err != nil {
+		failImport(e, j, err)
+		return
+	}
+	err = e.UpdateHistoryEncoding(cs, enc)
+	if err != nil {
+		log.Println(err)
+	}
+
+	/* Parse and index file entries, resuming from the stored checkpoint */
+	report := j.progress.report
+	saved := time.Now()
+	cp := newCheckpoint(j.Offset, j.Report, func(offset int, r *common.Report) {
+		if time.Since(saved) < checkpointInterval {
+			return
+		}
+		saved = time.Now()
+		err := e.UpdateJobCheckpoint(j.ID, offset, r)
+		if err != nil {
+			log.Println(err)
+		}
+	})
+	err = ingest(text, p, fn, cs, imports.jobWorkers(j), e.BulkInsert, report, cp)
 
 	/* Refresh elastic index */
 	e.Refresh()
-	/* Update history status (Complete)*/
-	e.UpdateHistoryStatus(cs, 1)
+
+	/* Cancelled jobs may be rolled back */
+	cancelled, rollback := j.cancelled()
+	if cancelled && rollback {
+		rollbackJob(e, j)
+		return
+	}
+
+	/* Store import report */
+	rErr := e.UpdateHistoryReport(cs, &report.report)
+	if rErr != nil {
+		log.Println(rErr)
+	}
+
+	/* Update history status */
+	status := common.StatusComplete
+	switch {
+	case cancelled:
+		log.Printf("Processing cancelled: %s", fn)
+		status = common.StatusCancelled
+	case err != nil:
+		log.Printf("(ERROR) (%s) %s", fn, err)
+		status = common.StatusFailed
+	case report.rejected():
+		status = common.StatusPartial
+	}
+	log.Printf(
+		"Processing complete: %s (%d lines, %d indexed, %d failed, %.0f docs/s)",
+		fn,
+		report.report.Lines,
+		report.report.Indexed,
+		report.report.Failed,
+		report.report.DocsPerSecond,
+	)
+	finishImport(e, j, status, j.status())
 }
 
 /*
-uploader :: Upload entries to elastic
+stopped :: Roll back or mark as cancelled a cancelled job, false if
+the job was not cancelled
 */
-func uploader(id int, wg *sync.WaitGroup, e *elastic.Client, quitChan <-chan struct{}, entryChan <-chan *common.Entry) {
-	wg.Add(1)
-	run := true
-	chunk := []*common.Entry{}
+func stopped(e *elastic.Client, j *job) bool {
+	cancelled, rollback := j.cancelled()
+	switch {
+	case !cancelled:
+		return false
+	case rollback:
+		rollbackJob(e, j)
+	default:
+		log.Printf("Processing cancelled: %s", j.Filename)
+		finishImport(e, j, common.StatusCancelled, j.status())
+	}
 
-	for run {
-		/* Chunk size reached */
-		if len(chunk) >= chunkSize {
-			err := e.BulkInsert(chunk)
-			if err != nil {
-				log.Println(err)
-			}
-			chunk = []*common.Entry{}
-		}
+	return true
+}
 
-		select {
-		case <-quitChan:
-			run = false
-		case entry := <-entryChan:
-			if entry == nil {
-				continue
-			}
-			/* Append document to chunk */
-			chunk = append(chunk, entry)
-		}
+/*
+failImport :: Finish a job that could not read its file, reads of
+cancelled jobs fail so they are stopped instead
+*/
+func failImport(e *elastic.Client, j *job, err error) {
+	if stopped(e, j) {
+		return
 	}
+	log.Printf("(ERROR) (%s) %s", j.Filename, err)
+	finishImport(e, j, common.StatusFailed, nil)
+}
 
-	/* If there is still data, upload chunk */
-	if len(chunk) > 0 {
-		err := e.BulkInsert(chunk)
+/*
+releaseFile :: Release an imported file according to its source
+*/
+func releaseFile(source string, fp string, imported bool) {
+	switch source {
+	case common.SourceWatch:
+		releaseWatched(fp, imported)
+	case common.SourceLocal:
+		/* Local files are left where they are */
+	default:
+		err := os.Remove(fp)
 		if err != nil {
 			log.Println(err)
 		}
 	}
+}
 
-	wg.Done()
+/*
+finishImport :: Update history status and notify clients
+*/
+func finishImport(e *elastic.Client, j *job, status int, progress *common.Progress) {
+	j.finish(status)
+	cs, fn := j.Checksum, j.Filename
+
+	err := e.UpdateHistoryStatus(cs, status)
+	if err != nil {
+		log.Println(err)
+	}
+
+	eventType := common.EventCompleted
+	switch status {
+	case common.StatusFailed:
+		eventType = common.EventFailed
+	case common.StatusCancelled:
+		eventType = common.EventCancelled
+	}
+	events.publish(eventType, cs, fn, status, progress)
 }
@@ -0,0 +1,80 @@
+package api
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUploadSettings(t *testing.T) {
+	_, e := newTestNode(t)
+	tests := []struct {
+		name   string
+		values map[string]string
+	}{
+		{name: "encoding", values: map[string]string{"pattern": "{:}{#}", "encoding": "unknown"}},
+		{name: "pattern", values: map[string]string{"pattern": "{:}{#}", "columns": "x:y:z:w"}},
+		{name: "workers", values: map[string]string{"pattern": "{:}{#}", "workers": "1000"}},
+		{name: "max line", values: map[string]string{"pattern": "{:}{#}", "max_line": "x"}},
+	}
+
+	for _, test := range tests {
+		body, writer := io.Pipe()
+		form := multipart.NewWriter(writer)
+		req := httptest.NewRequest(http.MethodPost, "/api/upload", body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+
+		/* The file is never completed, invalid settings are rejected before reading it */
+		go func(values map[string]string) {
+			for name, value := range values {
+				form.WriteField(name, value)
+			}
+			part, _ := form.CreateFormFile("file", "dump.txt")
+			part.Write([]byte(strings.Repeat("user@example.com:password\n", 1000)))
+		}(test.values)
+
+		w := httptest.NewRecorder()
+		done := make(chan struct{})
+		go func() {
+			upload(e)(w, req)
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: file read before validating settings", test.name)
+		}
+		body.Close()
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: got %d, want %d", test.name, w.Code, http.StatusBadRequest)
+		}
+	}
+}
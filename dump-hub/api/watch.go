@@ -0,0 +1,217 @@
+package api
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/x0e1f/dump-hub/common"
+	"github.com/x0e1f/dump-hub/elastic"
+)
+
+/* Time between two scans of the watch folder */
+const watchInterval = 10 * time.Second
+
+/* Suffix of settings files placed next to watched files */
+const sidecarSuffix = ".settings.json"
+
+/* Settings of watched files without sidecar file (combolist) */
+const defaultSidecar = `{"pattern": "{:}{#}", "columns": "0,1"}`
+
+/* Folders of the watch folder */
+const (
+	processingDir = ".processing"
+	processedDir  = "processed"
+	failedDir     = "failed"
+)
+
+/*
+watchFolder :: Poll drop folder and import files once they are not
+changed between two scans
+*/
+func watchFolder(e *elastic.Client, dir string) {
+	for _, d := range []string{processingDir, processedDir, failedDir} {
+		err := os.MkdirAll(path.Join(dir, d), 0755)
+		if err != nil {
+			log.Printf("(ERROR) Unable to watch %s: %s", dir, err)
+			return
+		}
+	}
+	log.Printf("Watching %s for new files...", dir)
+
+	/* Stored jobs are resumed, their files are still in use */
+	stored, err := e.GetJobs()
+	if err != nil {
+		log.Printf("(ERROR) Unable to recover %s: %s", dir, err)
+	} else {
+		recoverWatched(dir, stored)
+	}
+
+	sizes := map[string]int64{}
+	for {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			log.Println(err)
+		}
+
+		seen := map[string]int64{}
+		for _, f := range files {
+			name := f.Name()
+			if f.IsDir() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, sidecarSuffix) {
+				continue
+			}
+
+			/* Files still being copied are picked up later */
+			last, ok := sizes[name]
+			if !ok || last != f.Size() || time.Since(f.ModTime()) < watchInterval {
+				seen[name] = f.Size()
+				continue
+			}
+			importWatched(e, dir, name)
+		}
+		sizes = seen
+
+		time.Sleep(watchInterval)
+	}
+}
+
+/*
+recoverWatched :: Move back to the drop folder files left in the
+processing folder by a restart, files of stored jobs (imports and
+archive extractions) are kept
+*/
+func recoverWatched(dir string, stored []*common.Job) {
+	resumed := map[string]bool{}
+	for _, s := range stored {
+		resumed[s.Path] = true
+	}
+
+	files, err := ioutil.ReadDir(path.Join(dir, processingDir))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	for _, f := range files {
+		fp := path.Join(dir, processingDir, f.Name())
+		if resumed[fp] || resumed[strings.TrimSuffix(fp, sidecarSuffix)] {
+			continue
+		}
+		err := os.Rename(fp, path.Join(dir, f.Name()))
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+/*
+importWatched :: Move file to the processing folder and queue its
+import with the settings of its sidecar file
+*/
+func importWatched(e *elastic.Client, dir string, name string) {
+	log.Printf("New file in watch folder: %s", name)
+	fp := path.Join(dir, processingDir, name)
+	for _, suffix := range []string{"", sidecarSuffix} {
+		err := os.Rename(path.Join(dir, name+suffix), fp+suffix)
+		if err != nil && !os.IsNotExist(err) {
+			log.Printf("(ERROR) (%s) %s", name, err)
+			return
+		}
+	}
+
+	settings, err := sidecarSettings(fp + sidecarSuffix)
+	if err != nil {
+		log.Printf("(ERROR) (%s) %s", name, err)
+		releaseWatched(fp, false)
+		return
+	}
+	settings.source = common.SourceWatch
+
+	checkSum, err := fileChecksum(fp)
+	if err == nil {
+		_, err = submitUpload(e, settings, name, fp, checkSum)
+	}
+	if err == errDuplicate {
+		return
+	}
+	if err != nil {
+		log.Printf("(ERROR) (%s) %s", name, err)
+		releaseWatched(fp, false)
+	}
+}
+
+/*
+sidecarSettings :: Read upload settings from a sidecar file, a JSON
+object with the names of the upload form values. Lists (tags) and
+objects (members) are allowed. Files without sidecar are parsed
+as combolists
+*/
+func sidecarSettings(fp string) (*uploadSettings, error) {
+	data, err := ioutil.ReadFile(fp)
+	if os.IsNotExist(err) {
+		data, err = []byte(defaultSidecar), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]interface{}{}
+	err = json.Unmarshal(data, &values)
+	if err != nil {
+		return nil, fmt.Errorf("invalid settings file: %s", err)
+	}
+
+	return readSettings(jsonValue(values))
+}
+
+/*
+releaseWatched :: Move watched file (from the processing folder) and
+its sidecar file to the processed or failed folder, existing files
+are not overwritten
+*/
+func releaseWatched(fp string, imported bool) {
+	target := failedDir
+	if imported {
+		target = processedDir
+	}
+
+	dir := path.Dir(path.Dir(fp))
+	name := path.Base(fp)
+	dst := path.Join(dir, target, name)
+	if _, err := os.Stat(dst); err == nil {
+		name = time.Now().Format("20060102150405") + "-" + name
+		dst = path.Join(dir, target, name)
+	}
+	for _, suffix := range []string{"", sidecarSuffix} {
+		err := os.Rename(fp+suffix, dst+suffix)
+		if err != nil && !os.IsNotExist(err) {
+			log.Printf("(ERROR) (%s) %s", fp, err)
+		}
+	}
+}
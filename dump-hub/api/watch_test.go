@@ -0,0 +1,74 @@
+package api
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/x0e1f/dump-hub/common"
+)
+
+func TestRecoverWatched(t *testing.T) {
+	dir := t.TempDir()
+	processing := path.Join(dir, processingDir)
+	if err := os.Mkdir(processing, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := []string{
+		"dump.txt",
+		"dump.txt" + sidecarSuffix,
+		"archive.zip",
+		"archive.zip" + sidecarSuffix,
+		"left.txt",
+		"left.txt" + sidecarSuffix,
+	}
+	for _, name := range files {
+		if err := ioutil.WriteFile(path.Join(processing, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	/* Pending import and extraction jobs keep their files */
+	stored := []*common.Job{
+		{Type: common.JobImport, Path: path.Join(processing, "dump.txt")},
+		{Type: common.JobExtract, Path: path.Join(processing, "archive.zip")},
+	}
+	recoverWatched(dir, stored)
+
+	for _, name := range files {
+		_, err := os.Stat(path.Join(processing, name))
+		kept := err == nil
+		if want := name[:4] != "left"; kept != want {
+			t.Errorf("%s: kept in processing folder %t, want %t", name, kept, want)
+		}
+	}
+	for _, name := range []string{"left.txt", "left.txt" + sidecarSuffix} {
+		if _, err := os.Stat(path.Join(dir, name)); err != nil {
+			t.Errorf("%s: not moved back to the drop folder", name)
+		}
+	}
+}
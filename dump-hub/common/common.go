@@ -38,6 +38,21 @@ const EHost = "elasticsearch"
 // EPort :: Elasticsearch port
 const EPort = 9200
 
+// MaxJobs :: Imports running at the same time (DH_MAX_JOBS)
+const MaxJobs = 2
+
+// JobWorkers :: Default uploader routines of an import, 0 uses
+// the number of CPUs (DH_JOB_WORKERS)
+const JobWorkers = 0
+
+// WatchDir :: Folder of files imported from the server, empty
+// to disable (DH_WATCH_DIR)
+const WatchDir = ""
+
+// ImportDir :: Folder of files that can be imported in place from
+// the API, empty to disable (DH_IMPORT_DIR)
+const ImportDir = ""
+
 // Banner :: Dump Hub Cool Banner
 const Banner = `                          
    _                   _       _   
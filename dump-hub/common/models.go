@@ -27,21 +27,238 @@ OTHER DEALINGS IN THE SOFTWARE.
 Entry :: Entry document
 */
 type Entry struct {
-	Origin   string   `json:"origin"`
-	OriginID string   `json:"origin_id"`
-	Data     []string `json:"data"`
+	Origin   string            `json:"origin"`
+	OriginID string            `json:"origin_id"`
+	Fields   map[string]string `json:"fields"`
+	Types    map[string]string `json:"types,omitempty"`
+}
+
+/*
+ParserConfig :: Parser settings submitted with a dump file,
+Encoding is the input charset (detected when empty), MaxLine the
+maximum line length in bytes and LongLines the policy for longer
+lines (skip or truncate). Header makes the first line of text and
+CSV files the column names
+*/
+type ParserConfig struct {
+	Format    string `json:"format"`
+	Pattern   string `json:"pattern"`
+	Columns   string `json:"columns"`
+	Quote     string `json:"quote"`
+	Escape    string `json:"escape"`
+	Regex     string `json:"regex"`
+	Table     string `json:"table"`
+	Encoding  string `json:"encoding"`
+	MaxLine   int    `json:"max_line"`
+	LongLines string `json:"long_lines"`
+	Header    bool   `json:"header"`
+}
+
+/*
+Table :: Table found in a database dump
+*/
+type Table struct {
+	Name    string     `json:"name"`
+	Columns []string   `json:"columns"`
+	Rows    [][]string `json:"rows"`
 }
 
 /*
 History :: Dump Hub History document
 */
 type History struct {
-	Date     string `json:"date"`
-	Filename string `json:"filename"`
-	Checksum string `json:"checksum"`
-	Status   int    `json:"status"`
+	Date     string    `json:"date"`
+	Filename string    `json:"filename"`
+	Checksum string    `json:"checksum"`
+	Status   int       `json:"status"`
+	Encoding string    `json:"encoding"`
+	Tags     []string  `json:"tags,omitempty"`
+	Report   *Report   `json:"report,omitempty"`
+	Progress *Progress `json:"progress,omitempty"`
+}
+
+/*
+Progress :: Progress of a running import, Size and BytesRead refer
+to the uploaded file (compressed size), ETA is in seconds (-1 if
+not known yet). Queue is the position of a queued import (0 once
+it is running)
+*/
+type Progress struct {
+	Size           int64   `json:"size"`
+	BytesRead      int64   `json:"bytes_read"`
+	Percent        float64 `json:"percent"`
+	Lines          int     `json:"lines"`
+	Parsed         int     `json:"parsed"`
+	Indexed        int     `json:"indexed"`
+	Failed         int     `json:"failed"`
+	Seconds        float64 `json:"seconds"`
+	DocsPerSecond  float64 `json:"docs_per_second"`
+	BytesPerSecond float64 `json:"bytes_per_second"`
+	ETA            float64 `json:"eta"`
+	Job            string  `json:"job"`
+	Paused         bool    `json:"paused"`
+	Queue          int     `json:"queue"`
+	Priority       int     `json:"priority"`
+	Workers        int     `json:"workers"`
+}
+
+/*
+Job :: Import, delete, archive extraction or checksum job, Path is the location
+of the file to import. Offset counts the leading entries already indexed and Report
+is the import report up to Offset, a restarted import resumes from them.
+Queued imports with higher Priority start first, Workers is the number
+of uploader routines (0 for the default). Source tells what happens to
+the file once imported. Members and Tags are the settings of the
+members of an extracted archive
+*/
+type Job struct {
+	ID       string                   `json:"id"`
+	Type     string                   `json:"type"`
+	Date     string                   `json:"date"`
+	Checksum string                   `json:"checksum"`
+	Filename string                   `json:"filename"`
+	Path     string                   `json:"path"`
+	Source   string                   `json:"source"`
+	Config   *ParserConfig            `json:"config"`
+	Priority int                      `json:"priority"`
+	Workers  int                      `json:"workers"`
+	Offset   int                      `json:"offset"`
+	Report   *Report                  `json:"report,omitempty"`
+	Members  map[string]*ParserConfig `json:"members,omitempty"`
+	Tags     []string                 `json:"tags,omitempty"`
+}
+
+/* Job types */
+const (
+	JobImport  = "import"
+	JobDelete  = "delete"
+	JobExtract = "extract"
+	JobHash    = "hash"
+)
+
+/*
+Import sources :: Uploaded files are removed once imported, files of
+the watch folder are moved to the processed or failed folder and local
+files are kept
+*/
+const (
+	SourceUpload = "upload"
+	SourceWatch  = "watch"
+	SourceLocal  = "local"
+)
+
+/*
+Report :: Import report of a file, Lines counts the lines (records
+for multi-line formats) read, Errors the documents rejected by
+elastic by error type and Bytes the size of documents sent
+*/
+type Report struct {
+	Lines          int            `json:"lines"`
+	Parsed         int            `json:"parsed"`
+	Skipped        map[string]int `json:"skipped"`
+	Indexed        int            `json:"indexed"`
+	Failed         int            `json:"failed"`
+	Retries        int            `json:"retries"`
+	Errors         map[string]int `json:"errors"`
+	Bytes          int64          `json:"bytes"`
+	Seconds        float64        `json:"seconds"`
+	DocsPerSecond  float64        `json:"docs_per_second"`
+	BytesPerSecond float64        `json:"bytes_per_second"`
+	Rejected       []*Rejected    `json:"rejected,omitempty"`
+}
+
+/*
+Rejected :: Sample of a rejected line
+*/
+type Rejected struct {
+	Line   int    `json:"line"`
+	Raw    string `json:"raw"`
+	Reason string `json:"reason"`
+}
+
+/*
+Preview :: Entries parsed from a file sample, Skipped counts the
+skipped lines by reason and SkippedLines samples them (comments and
+empty lines included). Truncated is set if the sample is only the
+beginning of the file, Error is the parser error if any
+*/
+type Preview struct {
+	Entries      []*Entry       `json:"entries"`
+	Parsed       int            `json:"parsed"`
+	Skipped      map[string]int `json:"skipped"`
+	SkippedLines []*Rejected    `json:"skipped_lines"`
+	Encoding     string         `json:"encoding"`
+	Compression  string         `json:"compression"`
+	Truncated    bool           `json:"truncated"`
+	Error        string         `json:"error,omitempty"`
 }
 
+/*
+Detection :: Parser settings proposed for a file sample, Kind is the
+kind of dump (csv, json, sql, copy, combolist or text) and Header is
+set if the first line names the columns
+*/
+type Detection struct {
+	Kind    string            `json:"kind"`
+	Config  *ParserConfig     `json:"config"`
+	Header  bool              `json:"header"`
+	Columns []*DetectedColumn `json:"columns"`
+}
+
+/*
+DetectedColumn :: Column found in a file sample, Selector is the
+column index or name and Type the guessed field type (empty if none)
+*/
+type DetectedColumn struct {
+	Selector string `json:"selector"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Sample   string `json:"sample"`
+}
+
+/* History status values */
+const (
+	// StatusFailed :: Processing error
+	StatusFailed = -1
+	// StatusProcessing :: File is being processed
+	StatusProcessing = 0
+	// StatusComplete :: Every entry has been processed
+	StatusComplete = 1
+	// StatusDeleting :: Entries are being deleted
+	StatusDeleting = 2
+	// StatusPartial :: Processing complete, some lines were skipped
+	StatusPartial = 3
+	// StatusCancelled :: Processing cancelled
+	StatusCancelled = 4
+)
+
+/*
+Event :: Job event pushed to clients
+*/
+type Event struct {
+	Type     string    `json:"type"`
+	Checksum string    `json:"checksum"`
+	Filename string    `json:"filename,omitempty"`
+	Status   int       `json:"status"`
+	Progress *Progress `json:"progress,omitempty"`
+	Time     string    `json:"time"`
+}
+
+/* Job event types */
+const (
+	EventQueued    = "queued"
+	EventStarted   = "started"
+	EventProgress  = "progress"
+	EventCompleted = "completed"
+	EventFailed    = "failed"
+	EventPaused    = "paused"
+	EventResumed   = "resumed"
+	EventCancelled = "cancelled"
+	EventDeleting  = "deleting"
+	EventDeleted   = "deleted"
+	EventDuplicate = "duplicate"
+)
+
 /*
 HistoryData :: Dump Hub History API Response
 */
@@ -0,0 +1,162 @@
+package elastic
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+)
+
+const (
+	// BulkActions :: Maximum number of documents per bulk request
+	BulkActions = 1000
+	// BulkSize :: Maximum size of bulk request documents (bytes)
+	BulkSize = 5 * 1024 * 1024
+)
+
+/* Retries of throttled bulk requests and items */
+const bulkRetries = 6
+
+/* Wait between retries of throttled bulk requests */
+var bulkBackoff elastic.Backoff = elastic.NewExponentialBackoff(
+	500*time.Millisecond,
+	30*time.Second,
+)
+
+/*
+BulkResult :: Outcome of a bulk request, Errors counts failed
+documents by elastic error type
+*/
+type BulkResult struct {
+	Indexed int
+	Failed  int
+	Retries int
+	Errors  map[string]int
+}
+
+/*
+Document :: Entry document, documents are indexed by ID so that
+indexing them again does not create duplicates
+*/
+type Document struct {
+	ID     string
+	Source json.RawMessage
+}
+
+/*
+BulkInsert :: Elasticsearch Bulk API. Requests and documents rejected
+because the cluster is overloaded (429, 503) are retried with an
+exponential backoff, other document errors are counted as failures
+*/
+func (eClient *Client) BulkInsert(docs []*Document) (*BulkResult, error) {
+	result := &BulkResult{
+		Errors: map[string]int{},
+	}
+
+	pending := docs
+	for retry := 0; ; retry++ {
+		retryDocs, err := eClient.bulkRequest(pending, result)
+		if err == nil && len(retryDocs) < 1 {
+			return result, nil
+		}
+		if err != nil {
+			if !isThrottled(err) && !elastic.IsConnErr(err) && !elastic.IsTimeout(err) {
+				result.Failed += len(pending)
+				result.Errors["request error"] += len(pending)
+				return result, err
+			}
+			retryDocs = pending
+		}
+
+		/* Wait before sending documents again */
+		wait, ok := bulkBackoff.Next(retry)
+		if !ok || retry >= bulkRetries {
+			result.Failed += len(retryDocs)
+			result.Errors["retries exhausted"] += len(retryDocs)
+			return result, err
+		}
+		log.Printf("Bulk request throttled, retrying %d documents in %s", len(retryDocs), wait)
+		time.Sleep(wait)
+		result.Retries++
+		pending = retryDocs
+	}
+}
+
+/*
+bulkRequest :: Send a single bulk request, returns the documents
+that should be retried
+*/
+func (eClient *Client) bulkRequest(docs []*Document, result *BulkResult) ([]*Document, error) {
+	bulkRequest := eClient.client.Bulk()
+	for _, doc := range docs {
+		req := elastic.NewBulkIndexRequest().
+			OpType("index").
+			Index("dump-hub").
+			Id(doc.ID).
+			Doc(doc.Source)
+
+		bulkRequest = bulkRequest.Add(req)
+	}
+
+	response, err := bulkRequest.
+		Do(eClient.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	/* Items are in the same order of the documents */
+	retryDocs := []*Document{}
+	for i, items := range response.Items {
+		for _, item := range items {
+			switch {
+			case item.Status >= 200 && item.Status <= 299:
+				result.Indexed++
+			case item.Status == http.StatusTooManyRequests || item.Status == http.StatusServiceUnavailable:
+				retryDocs = append(retryDocs, docs[i])
+			default:
+				result.Failed++
+				errorType := "unknown error"
+				if item.Error != nil {
+					errorType = item.Error.Type
+					log.Printf("(ERROR) Bulk item error: (%s) %s", item.Error.Type, item.Error.Reason)
+				}
+				result.Errors[errorType]++
+			}
+		}
+	}
+
+	return retryDocs, nil
+}
+
+/*
+isThrottled :: Check if elastic rejected a request because overloaded
+*/
+func isThrottled(err error) bool {
+	return elastic.IsStatusCode(err, http.StatusTooManyRequests) ||
+		elastic.IsStatusCode(err, http.StatusServiceUnavailable)
+}
@@ -0,0 +1,179 @@
+package elastic
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+)
+
+/*
+bulkResponse :: Fake response to a bulk request, a status other than
+200 fails the whole request, items are the statuses of the documents
+*/
+type bulkResponse struct {
+	status int
+	items  []int
+}
+
+/*
+bulkServer :: Fake bulk API, requests get the responses in order and
+the IDs of the documents of every request are recorded
+*/
+func bulkServer(t *testing.T, responses []bulkResponse, requests *[][]string) *Client {
+	return newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		ids := []string{}
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var action map[string]struct {
+				ID string `json:"_id"`
+			}
+			if json.Unmarshal(scanner.Bytes(), &action) == nil {
+				if index, ok := action["index"]; ok && len(index.ID) > 0 {
+					ids = append(ids, index.ID)
+				}
+			}
+		}
+		*requests = append(*requests, ids)
+
+		response := responses[len(responses)-1]
+		if len(*requests) <= len(responses) {
+			response = responses[len(*requests)-1]
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if response.status != http.StatusOK {
+			w.WriteHeader(response.status)
+			fmt.Fprintf(w, `{"error":{"type":"error","reason":"status %d"},"status":%d}`, response.status, response.status)
+			return
+		}
+
+		items := []string{}
+		for i, id := range ids {
+			status := response.items[i]
+			item := fmt.Sprintf(`"_index":"dump-hub","_id":%q,"status":%d`, id, status)
+			if status == http.StatusBadRequest {
+				item += `,"error":{"type":"mapper_parsing_exception","reason":"failed to parse"}`
+			}
+			if status == http.StatusTooManyRequests {
+				item += `,"error":{"type":"es_rejected_execution_exception","reason":"rejected"}`
+			}
+			items = append(items, `{"index":{`+item+`}}`)
+		}
+		fmt.Fprintf(w, `{"took":1,"errors":true,"items":[%s]}`, strings.Join(items, ","))
+	})
+}
+
+func TestBulkInsert(t *testing.T) {
+	/* Retries do not wait */
+	defer func(backoff elastic.Backoff) { bulkBackoff = backoff }(bulkBackoff)
+	bulkBackoff = elastic.NewConstantBackoff(time.Millisecond)
+
+	tests := []struct {
+		name      string
+		responses []bulkResponse
+		requests  [][]string
+		result    BulkResult
+		err       bool
+	}{
+		{
+			name:      "indexed",
+			responses: []bulkResponse{{status: 200, items: []int{201, 201, 200}}},
+			requests:  [][]string{{"1", "2", "3"}},
+			result:    BulkResult{Indexed: 3, Errors: map[string]int{}},
+		},
+		{
+			name: "throttled items",
+			responses: []bulkResponse{
+				{status: 200, items: []int{201, 429, 503}},
+				{status: 200, items: []int{429, 201}},
+				{status: 200, items: []int{201}},
+			},
+			requests: [][]string{{"1", "2", "3"}, {"2", "3"}, {"2"}},
+			result:   BulkResult{Indexed: 3, Retries: 2, Errors: map[string]int{}},
+		},
+		{
+			name:      "permanent item error",
+			responses: []bulkResponse{{status: 200, items: []int{201, 400, 201}}},
+			requests:  [][]string{{"1", "2", "3"}},
+			result:    BulkResult{Indexed: 2, Failed: 1, Errors: map[string]int{"mapper_parsing_exception": 1}},
+		},
+		{
+			name: "throttled request",
+			responses: []bulkResponse{
+				{status: 429},
+				{status: 200, items: []int{201, 201, 201}},
+			},
+			requests: [][]string{{"1", "2", "3"}, {"1", "2", "3"}},
+			result:   BulkResult{Indexed: 3, Retries: 1, Errors: map[string]int{}},
+		},
+		{
+			name:      "failed request",
+			responses: []bulkResponse{{status: 400}},
+			requests:  [][]string{{"1", "2", "3"}},
+			result:    BulkResult{Failed: 3, Errors: map[string]int{"request error": 3}},
+			err:       true,
+		},
+		{
+			name:      "retries exhausted",
+			responses: []bulkResponse{{status: 200, items: []int{201, 429, 429}}, {status: 200, items: []int{429, 429}}},
+			requests: [][]string{
+				{"1", "2", "3"}, {"2", "3"}, {"2", "3"}, {"2", "3"},
+				{"2", "3"}, {"2", "3"}, {"2", "3"},
+			},
+			result: BulkResult{Indexed: 1, Failed: 2, Retries: bulkRetries, Errors: map[string]int{"retries exhausted": 2}},
+		},
+	}
+
+	for _, test := range tests {
+		requests := [][]string{}
+		e := bulkServer(t, test.responses, &requests)
+
+		docs := []*Document{}
+		for i := 1; i <= 3; i++ {
+			docs = append(docs, &Document{
+				ID:     strconv.Itoa(i),
+				Source: json.RawMessage(`{"fields":{"0":"a"}}`),
+			})
+		}
+		result, err := e.BulkInsert(docs)
+		if (err != nil) != test.err {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+		if !reflect.DeepEqual(requests, test.requests) {
+			t.Errorf("%s: requests\n got %v\nwant %v", test.name, requests, test.requests)
+		}
+		if !reflect.DeepEqual(*result, test.result) {
+			t.Errorf("%s: result\n got %+v\nwant %+v", test.name, *result, test.result)
+		}
+	}
+}
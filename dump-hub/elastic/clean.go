@@ -32,36 +32,52 @@ import (
 	"sync"
 
 	"github.com/olivere/elastic/v7"
+	"github.com/x0e1f/dump-hub/common"
 )
 
 /*
-cleanTmp :: Clean tmp folder
+cleanTmp :: Clean tmp folder, files of stored jobs are kept
 */
-func cleanTmp(wg *sync.WaitGroup) {
+func cleanTmp(wg *sync.WaitGroup, jobs []*common.Job) {
 	log.Println("Cleaning tmp folder...")
 	defer wg.Done()
 
+	keep := map[string]bool{}
+	for _, job := range jobs {
+		keep[job.Path] = true
+	}
+
 	dir, err := ioutil.ReadDir("/tmp")
 	if err != nil {
 		log.Println(err)
 	}
 	for _, d := range dir {
-		if d.Name() != ".gitkeep" {
+		/* Pending resumable uploads are expired by the api */
+		if d.Name() == "uploads" {
+			continue
+		}
+		if d.Name() != ".gitkeep" && !keep[path.Join("/tmp", d.Name())] {
 			os.Remove(path.Join("/tmp", d.Name()))
 		}
 	}
 }
 
 /*
-cleanHistory :: Clean unprocessed files and update history status
+cleanHistory :: Clean unprocessed files and update history status,
+files with a stored job are resumed instead
 */
-func (eClient *Client) cleanHistory(wg *sync.WaitGroup) {
+func (eClient *Client) cleanHistory(wg *sync.WaitGroup, jobs []*common.Job) {
 	log.Println("Cleaning history of unprocessed files...")
 	defer wg.Done()
 
+	resumed := map[string]bool{}
+	for _, job := range jobs {
+		resumed[job.Checksum] = true
+	}
+
 	matchQ := elastic.NewMatchQuery(
 		"status",
-		0,
+		common.StatusProcessing,
 	)
 	query := elastic.
 		NewBoolQuery().
@@ -82,7 +98,10 @@ func (eClient *Client) cleanHistory(wg *sync.WaitGroup) {
 		}
 
 		for _, hit := range result.Hits.Hits {
-			err = eClient.UpdateHistoryStatus(hit.Id, -1)
+			if resumed[hit.Id] {
+				continue
+			}
+			err = eClient.UpdateHistoryStatus(hit.Id, common.StatusFailed)
 			if err != nil {
 				log.Println(err)
 			}
@@ -91,7 +110,7 @@ func (eClient *Client) cleanHistory(wg *sync.WaitGroup) {
 
 	matchQ = elastic.NewMatchQuery(
 		"status",
-		2,
+		common.StatusDeleting,
 	)
 	query = elastic.
 		NewBoolQuery().
@@ -112,7 +131,10 @@ func (eClient *Client) cleanHistory(wg *sync.WaitGroup) {
 		}
 
 		for _, hit := range result.Hits.Hits {
-			err = eClient.UpdateHistoryStatus(hit.Id, -1)
+			if resumed[hit.Id] {
+				continue
+			}
+			err = eClient.UpdateHistoryStatus(hit.Id, common.StatusFailed)
 			if err != nil {
 				log.Println(err)
 			}
@@ -74,14 +74,44 @@ func New(ip string, port int) *Client {
 	if err != nil {
 		log.Fatal(err)
 	}
+	err = e.CreateIndex("dump-hub-jobs", jobMapping)
+	if err != nil {
+		log.Fatal(err)
+	}
 	e.waitGreen()
 
+	/* Files and history of stored jobs are kept to resume them */
+	jobs, err := e.GetJobs()
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	var wg sync.WaitGroup
 	wg.Add(2)
 
-	go e.cleanHistory(&wg)
-	go cleanTmp(&wg)
+	go e.cleanHistory(&wg, jobs)
+	go cleanTmp(&wg, jobs)
 	wg.Wait()
 
 	return e
 }
+
+/*
+Connect :: Client of the elasticsearch node at url, the node is not
+waited for and indices are not created
+*/
+func Connect(url string) (*Client, error) {
+	client, err := elastic.NewClient(
+		elastic.SetURL(url),
+		elastic.SetSniff(false),
+		elastic.SetHealthcheck(false),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		client: client,
+		ctx:    context.Background(),
+	}, nil
+}
@@ -0,0 +1,118 @@
+package elastic
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+
+	"github.com/x0e1f/dump-hub/common"
+)
+
+/*
+entryValue :: Named value of an entry document. Values are stored as
+a nested array so column names never become mapped fields, the index
+mapping stays the same whatever columns are imported
+*/
+type entryValue struct {
+	Name  string `json:"name"`
+	Type  string `json:"type,omitempty"`
+	Value string `json:"value"`
+}
+
+/*
+entrySource :: Entry document stored on dump-hub index
+*/
+type entrySource struct {
+	Origin   string            `json:"origin"`
+	OriginID string            `json:"origin_id"`
+	Values   []entryValue      `json:"values,omitempty"`
+	Fields   map[string]string `json:"fields,omitempty"`
+	Types    map[string]string `json:"types,omitempty"`
+	Data     []string          `json:"data,omitempty"`
+}
+
+/*
+EncodeEntry :: Source of an entry document, values are sorted by name
+*/
+func EncodeEntry(entry *common.Entry) ([]byte, error) {
+	source := entrySource{
+		Origin:   entry.Origin,
+		OriginID: entry.OriginID,
+		Values:   []entryValue{},
+	}
+	for name, value := range entry.Fields {
+		source.Values = append(source.Values, entryValue{
+			Name:  name,
+			Type:  entry.Types[name],
+			Value: value,
+		})
+	}
+	sort.Slice(source.Values, func(i, j int) bool {
+		return source.Values[i].Name < source.Values[j].Name
+	})
+
+	return json.Marshal(source)
+}
+
+/*
+DecodeEntry :: Entry of a document source. Documents indexed before
+nested values store named fields in fields (and types), older ones
+store values in data
+*/
+func DecodeEntry(data []byte) (*common.Entry, error) {
+	source := entrySource{}
+	err := json.Unmarshal(data, &source)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &common.Entry{
+		Origin:   source.Origin,
+		OriginID: source.OriginID,
+		Fields:   source.Fields,
+		Types:    source.Types,
+	}
+	if entry.Fields != nil {
+		return entry, nil
+	}
+
+	entry.Fields = map[string]string{}
+	for _, v := range source.Values {
+		entry.Fields[v.Name] = v.Value
+		if len(v.Type) < 1 {
+			continue
+		}
+		if entry.Types == nil {
+			entry.Types = map[string]string{}
+		}
+		entry.Types[v.Name] = v.Type
+	}
+	for i, value := range source.Data {
+		entry.Fields[strconv.Itoa(i)] = value
+	}
+
+	return entry, nil
+}
@@ -25,7 +25,7 @@ OTHER DEALINGS IN THE SOFTWARE.
 
 import (
 	"encoding/json"
-	"io"
+	"fmt"
 	"log"
 
 	"github.com/olivere/elastic/v7"
@@ -33,7 +33,9 @@ import (
 )
 
 /*
-CreateIndex :: Create elasticsearch index if not exists
+CreateIndex :: Create elasticsearch index if not exists, the mapping
+of an existing index is updated so new dynamic templates and fields
+apply to the documents indexed from now on
 */
 func (eClient *Client) CreateIndex(index string, mapping string) error {
 	exists, err := eClient.client.IndexExists(index).Do(eClient.ctx)
@@ -41,42 +43,46 @@ func (eClient *Client) CreateIndex(index string, mapping string) error {
 		return err
 	}
 
-	if !exists {
-		_, err := eClient.client.
-			CreateIndex(index).
-			Body(mapping).
-			Do(eClient.ctx)
+	if exists {
+		/* Fields already mapped can not change, the index stays usable */
+		err := eClient.updateMapping(index, mapping)
 		if err != nil {
-			return err
+			log.Printf("(ERROR) Unable to update mapping of %s: %s", index, err)
 		}
-		log.Printf("Created elasticsearch index: %s", index)
+		return nil
 	}
 
+	_, err = eClient.client.
+		CreateIndex(index).
+		Body(mapping).
+		Do(eClient.ctx)
+	if err != nil {
+		return err
+	}
+	log.Printf("Created elasticsearch index: %s", index)
+
 	return nil
 }
 
 /*
-BulkInsert :: Elasticsearch Bulk API
+updateMapping :: Put dynamic templates and properties of an index
+mapping, dynamic templates replace the stored ones
 */
-func (eClient *Client) BulkInsert(e []*common.Entry) error {
-	bulkRequest := eClient.client.Bulk()
-
-	for _, entry := range e {
-		req := elastic.NewBulkIndexRequest().
-			OpType("index").
-			Index("dump-hub").
-			Doc(entry)
-
-		bulkRequest = bulkRequest.Add(req)
+func (eClient *Client) updateMapping(index string, mapping string) error {
+	var body struct {
+		Mappings map[string]interface{} `json:"mappings"`
 	}
-
-	_, err := bulkRequest.
-		Do(eClient.ctx)
+	err := json.Unmarshal([]byte(mapping), &body)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	_, err = eClient.client.PutMapping().
+		Index(index).
+		BodyJson(body.Mappings).
+		Do(eClient.ctx)
+
+	return err
 }
 
 /*
@@ -133,7 +139,65 @@ func (eClient *Client) UpdateHistoryStatus(checkSum string, newStatus int) error
 }
 
 /*
-GetHistory :: Get history documents
+UpdateHistoryEncoding :: Update encoding field of an history element
+*/
+func (eClient *Client) UpdateHistoryEncoding(checkSum string, encoding string) error {
+	_, err := eClient.client.Update().
+		Index("dump-hub-history").
+		Id(checkSum).
+		Doc(map[string]interface{}{"encoding": encoding}).
+		Do(eClient.ctx)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+/*
+UpdateHistoryReport :: Update import report of an history element
+*/
+func (eClient *Client) UpdateHistoryReport(checkSum string, report *common.Report) error {
+	_, err := eClient.client.Update().
+		Index("dump-hub-history").
+		Id(checkSum).
+		Doc(map[string]interface{}{"report": report}).
+		Do(eClient.ctx)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+/*
+GetReport :: Get import report of an history element, nil if
+the element or its report does not exist
+*/
+func (eClient *Client) GetReport(checkSum string) (*common.Report, error) {
+	result, err := eClient.client.Get().
+		Index("dump-hub-history").
+		Id(checkSum).
+		Do(eClient.ctx)
+	if elastic.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	history := common.History{}
+	err = json.Unmarshal(result.Source, &history)
+	if err != nil {
+		return nil, err
+	}
+
+	return history.Report, nil
+}
+
+/*
+GetHistory :: Get history documents, rejected lines samples of
+reports are not included
 */
 func (eClient *Client) GetHistory(from int, size int) (*common.HistoryData, error) {
 	query := elastic.NewMatchAllQuery()
@@ -142,6 +206,9 @@ func (eClient *Client) GetHistory(from int, size int) (*common.HistoryData, erro
 	results, err := eClient.client.Search().
 		Index("dump-hub-history").
 		Query(query).
+		FetchSourceContext(
+			elastic.NewFetchSourceContext(true).Exclude("report.rejected"),
+		).
 		From(from).
 		Size(size).
 		Do(eClient.ctx)
@@ -171,60 +238,38 @@ func (eClient *Client) GetHistory(from int, size int) (*common.HistoryData, erro
 }
 
 /*
-DeleteEntries :: Delete entries associated to a file (checkSum)
+DeleteEntries :: Delete entries associated to a file (checkSum) and
+its history element, the history element is marked as failed if some
+entries could not be deleted
 */
-func (eClient *Client) DeleteEntries(checkSum string) {
-	eClient.UpdateHistoryStatus(checkSum, 2)
-
-	matchQ := elastic.NewMatchQuery(
-		"origin_id",
-		checkSum,
-	)
-	query := elastic.
-		NewBoolQuery().
-		Must(matchQ)
-
-	scroll := eClient.client.Scroll().
-		Index("dump-hub").
-		Query(query).
-		Size(1)
-
-	for {
-		result, err := scroll.Do(eClient.ctx)
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Println(err)
-		}
+func (eClient *Client) DeleteEntries(checkSum string) error {
+	err := eClient.UpdateHistoryStatus(checkSum, common.StatusDeleting)
+	if err != nil && !elastic.IsNotFound(err) {
+		log.Println(err)
+	}
 
-		for _, hit := range result.Hits.Hits {
-			eClient.deleteEntry(hit.Id)
+	response, err := eClient.client.DeleteByQuery("dump-hub").
+		Query(elastic.NewMatchQuery("origin_id", checkSum)).
+		Conflicts("proceed").
+		Refresh("true").
+		Do(eClient.ctx)
+	if err == nil && len(response.Failures) > 0 {
+		err = fmt.Errorf("%d entries not deleted", len(response.Failures))
+	}
+	if err != nil {
+		sErr := eClient.UpdateHistoryStatus(checkSum, common.StatusFailed)
+		if sErr != nil && !elastic.IsNotFound(sErr) {
+			log.Println(sErr)
 		}
+		return err
 	}
 
-	/* Refresh elastic index */
-	eClient.Refresh()
-
 	/* Delete history element */
-	_, err := eClient.client.Delete().
+	_, err = eClient.client.Delete().
 		Index("dump-hub-history").
 		Id(checkSum).
 		Do(eClient.ctx)
-	if err != nil {
-		log.Println(err)
-	}
-}
-
-/*
-deleteItem :: Delete item on elasticsearch
-*/
-func (eClient *Client) deleteEntry(ID string) error {
-	_, err := eClient.client.Delete().
-		Index("dump-hub").
-		Id(ID).
-		Do(eClient.ctx)
-	if err != nil {
+	if err != nil && !elastic.IsNotFound(err) {
 		return err
 	}
 
@@ -246,46 +291,50 @@ func (eClient *Client) Refresh() error {
 }
 
 /*
-Search :: Search entries using simple query string API
+Search :: Search entries using simple query string API,
+field restricts the search to a single named field
 */
-func (eClient *Client) Search(queryString string, from int, size int) (*common.SearchResult, error) {
-	var results *elastic.SearchResult
-	var err error
-
-	if len(queryString) < 1 || queryString == "*" {
-		query := elastic.NewMatchAllQuery()
-		results, err = eClient.client.Search().
-			Index("dump-hub").
-			Query(query).
-			From(from).
-			Size(size).
-			Do(eClient.ctx)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		query := elastic.
+func (eClient *Client) Search(queryString string, field string, from int, size int) (*common.SearchResult, error) {
+	var query elastic.Query
+
+	switch {
+	case len(queryString) < 1 || queryString == "*":
+		query = elastic.NewMatchAllQuery()
+	case len(field) > 0:
+		/* Entries indexed before nested values store named fields */
+		values := elastic.NewBoolQuery().Must(
+			elastic.NewTermQuery("values.name", field),
+			elastic.NewMatchPhraseQuery("values.value", queryString),
+		)
+		query = elastic.NewBoolQuery().
+			Should(
+				elastic.NewNestedQuery("values", values),
+				elastic.NewMatchPhraseQuery("fields."+field, queryString),
+			).
+			MinimumNumberShouldMatch(1)
+	default:
+		query = elastic.
 			NewMultiMatchQuery(
 				queryString,
 				"_all",
 			).
 			Type("match_phrase")
-		results, err = eClient.client.Search().
-			Index("dump-hub").
-			Query(query).
-			From(from).
-			Size(size).
-			Do(eClient.ctx)
-		if err != nil {
-			return nil, err
-		}
+	}
+
+	results, err := eClient.client.Search().
+		Index("dump-hub").
+		Query(query).
+		From(from).
+		Size(size).
+		Do(eClient.ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	/* Populate search results */
 	searchResult := common.SearchResult{}
 	for _, hit := range results.Hits.Hits {
-		entry := common.Entry{}
-		err := json.Unmarshal(hit.Source, &entry)
+		entry, err := DecodeEntry(hit.Source)
 		if err != nil {
 			log.Println(err)
 			break
@@ -293,7 +342,7 @@ func (eClient *Client) Search(queryString string, from int, size int) (*common.S
 
 		searchResult.Results = append(
 			searchResult.Results,
-			entry,
+			*entry,
 		)
 	}
 	searchResult.Tot = int(results.Hits.TotalHits.Value)
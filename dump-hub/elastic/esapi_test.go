@@ -0,0 +1,261 @@
+package elastic
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/x0e1f/dump-hub/common"
+)
+
+/*
+newTestClient :: Client of a fake elasticsearch node
+*/
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	e, err := Connect(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return e
+}
+
+func TestCreateIndex(t *testing.T) {
+	tests := []struct {
+		name     string
+		exists   bool
+		status   int
+		path     string
+		settings bool
+	}{
+		{name: "new index", path: "/dump-hub", status: http.StatusOK, settings: true},
+		{name: "existing index", exists: true, path: "/dump-hub/_mapping", status: http.StatusOK},
+		{name: "mapping conflict", exists: true, path: "/dump-hub/_mapping", status: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		var body map[string]interface{}
+		path := ""
+		e := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if r.Method == http.MethodHead {
+				if !test.exists {
+					w.WriteHeader(http.StatusNotFound)
+				}
+				return
+			}
+
+			path = r.URL.Path
+			data, _ := ioutil.ReadAll(r.Body)
+			json.Unmarshal(data, &body)
+			w.WriteHeader(test.status)
+			if test.status != http.StatusOK {
+				w.Write([]byte(`{"error":{"type":"illegal_argument_exception","reason":"conflict"},"status":400}`))
+				return
+			}
+			w.Write([]byte(`{"acknowledged":true}`))
+		})
+
+		err := e.CreateIndex("dump-hub", entryMapping)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if path != test.path {
+			t.Errorf("%s: got request to %q, want %q", test.name, path, test.path)
+			continue
+		}
+
+		/* Existing indices get the mapping without index settings */
+		mapping := body
+		if test.settings {
+			if _, ok := body["settings"]; !ok {
+				t.Errorf("%s: settings not sent", test.name)
+			}
+			mapping, _ = body["mappings"].(map[string]interface{})
+		}
+		templates, _ := mapping["dynamic_templates"].([]interface{})
+		properties, _ := mapping["properties"].(map[string]interface{})
+		if len(templates) < 1 || properties["values"] == nil || mapping["settings"] != nil {
+			t.Errorf("%s: unexpected mapping %v", test.name, mapping)
+		}
+	}
+}
+
+func TestDeleteEntries(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		status   int
+		failed   bool
+	}{
+		{name: "deleted", response: `{"deleted":3,"failures":[]}`, status: http.StatusOK},
+		{name: "failures", response: `{"deleted":2,"failures":[{"index":"dump-hub","id":"1","status":409}]}`, status: http.StatusOK, failed: true},
+		{name: "request error", response: `{"error":{"type":"search_phase_execution_exception","reason":"failed"},"status":503}`, status: http.StatusServiceUnavailable, failed: true},
+	}
+
+	for _, test := range tests {
+		statuses := []float64{}
+		historyDeleted := false
+		e := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			switch {
+			case r.URL.Path == "/dump-hub-history/_update/checksum":
+				var body struct {
+					Doc map[string]interface{} `json:"doc"`
+				}
+				json.NewDecoder(r.Body).Decode(&body)
+				status, _ := body.Doc["status"].(float64)
+				statuses = append(statuses, status)
+				w.Write([]byte(`{"result":"updated"}`))
+			case r.URL.Path == "/dump-hub/_delete_by_query":
+				var body map[string]interface{}
+				json.NewDecoder(r.Body).Decode(&body)
+				data, _ := json.Marshal(body["query"])
+				if string(data) != `{"match":{"origin_id":{"query":"checksum"}}}` {
+					t.Errorf("%s: unexpected query %s", test.name, data)
+				}
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.response))
+			case r.Method == http.MethodDelete && r.URL.Path == "/dump-hub-history/_doc/checksum":
+				historyDeleted = true
+				w.Write([]byte(`{"result":"deleted"}`))
+			default:
+				t.Errorf("%s: unexpected request %s %s", test.name, r.Method, r.URL.Path)
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		})
+
+		err := e.DeleteEntries("checksum")
+		if test.failed != (err != nil) {
+			t.Errorf("%s: got error %v", test.name, err)
+		}
+		if historyDeleted == test.failed {
+			t.Errorf("%s: history deleted %t", test.name, historyDeleted)
+		}
+
+		/* Failed deletions keep the history element with the failed status */
+		want := []float64{common.StatusDeleting}
+		if test.failed {
+			want = append(want, common.StatusFailed)
+		}
+		if fmt.Sprint(statuses) != fmt.Sprint(want) {
+			t.Errorf("%s: history status updates %v, want %v", test.name, statuses, want)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	var query string
+	e := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		data, _ := json.Marshal(body["query"])
+		query = string(data)
+
+		/* Nested values, named fields and values of older entries */
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"hits":{"total":{"value":3},"hits":[
+			{"_source":{"origin":"a","origin_id":"1","values":[{"name":"email","type":"email","value":"a@b.c"},{"name":"pw","value":"x"}]}},
+			{"_source":{"origin":"b","origin_id":"2","fields":{"email":"d@e.f"},"types":{"email":"email"}}},
+			{"_source":{"origin":"c","origin_id":"3","data":["g@h.i","y"]}}
+		]}}`))
+	})
+
+	result, err := e.Search("a@b.c", "email", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"nested":{"path":"values"`,
+		`{"term":{"values.name":"email"}}`,
+		`{"match_phrase":{"values.value":{"query":"a@b.c"}}}`,
+		`{"match_phrase":{"fields.email":{"query":"a@b.c"}}}`,
+	} {
+		if !strings.Contains(query, want) {
+			t.Errorf("query %s does not contain %s", query, want)
+		}
+	}
+
+	want := []common.Entry{
+		{
+			Origin:   "a",
+			OriginID: "1",
+			Fields:   map[string]string{"email": "a@b.c", "pw": "x"},
+			Types:    map[string]string{"email": "email"},
+		},
+		{
+			Origin:   "b",
+			OriginID: "2",
+			Fields:   map[string]string{"email": "d@e.f"},
+			Types:    map[string]string{"email": "email"},
+		},
+		{
+			Origin:   "c",
+			OriginID: "3",
+			Fields:   map[string]string{"0": "g@h.i", "1": "y"},
+		},
+	}
+	if result.Tot != 3 || !reflect.DeepEqual(result.Results, want) {
+		t.Errorf("got %d results %+v\nwant %+v", result.Tot, result.Results, want)
+	}
+}
+
+func TestEncodeEntry(t *testing.T) {
+	entry := &common.Entry{
+		Origin:   "dump.txt",
+		OriginID: "checksum",
+		Fields:   map[string]string{"password": "x", "email": "a@b.c"},
+		Types:    map[string]string{"email": "email"},
+	}
+
+	data, err := EncodeEntry(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"origin":"dump.txt","origin_id":"checksum","values":[` +
+		`{"name":"email","type":"email","value":"a@b.c"},{"name":"password","value":"x"}]}`
+	if string(data) != want {
+		t.Errorf("got %s\nwant %s", data, want)
+	}
+
+	decoded, err := DecodeEntry(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, entry) {
+		t.Errorf("decoded %+v, want %+v", decoded, entry)
+	}
+}
@@ -0,0 +1,123 @@
+package elastic
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/olivere/elastic/v7"
+	"github.com/x0e1f/dump-hub/common"
+)
+
+/*
+SaveJob :: Store job document, jobs are kept until they are
+finished so they can be resumed after a restart
+*/
+func (eClient *Client) SaveJob(job *common.Job) error {
+	_, err := eClient.client.Index().
+		Index("dump-hub-jobs").
+		Id(job.ID).
+		BodyJson(job).
+		Do(eClient.ctx)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+/*
+UpdateJobCheckpoint :: Update resume offset and report of a job
+*/
+func (eClient *Client) UpdateJobCheckpoint(ID string, offset int, report *common.Report) error {
+	_, err := eClient.client.Update().
+		Index("dump-hub-jobs").
+		Id(ID).
+		Doc(map[string]interface{}{
+			"offset": offset,
+			"report": report,
+		}).
+		Do(eClient.ctx)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+/*
+DeleteJob :: Delete job document of a finished job
+*/
+func (eClient *Client) DeleteJob(ID string) error {
+	_, err := eClient.client.Delete().
+		Index("dump-hub-jobs").
+		Id(ID).
+		Do(eClient.ctx)
+	if err != nil && !elastic.IsNotFound(err) {
+		return err
+	}
+
+	return nil
+}
+
+/*
+GetJobs :: Get stored jobs, oldest first
+*/
+func (eClient *Client) GetJobs() ([]*common.Job, error) {
+	_, err := eClient.client.Refresh().
+		Index("dump-hub-jobs").
+		Do(eClient.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	scroll := eClient.client.Scroll().
+		Index("dump-hub-jobs").
+		Query(elastic.NewMatchAllQuery()).
+		Sort("date", true).
+		Size(100)
+
+	jobs := []*common.Job{}
+	for {
+		result, err := scroll.Do(eClient.ctx)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, hit := range result.Hits.Hits {
+			job := &common.Job{}
+			err := json.Unmarshal(hit.Source, job)
+			if err != nil {
+				return nil, err
+			}
+			jobs = append(jobs, job)
+		}
+	}
+
+	return jobs, nil
+}
@@ -22,6 +22,27 @@ const entryMapping = `
     "properties": {
       "_all": {
         "type": "text"
+      },
+      "values": {
+        "type": "nested",
+        "properties": {
+          "name": {
+            "type": "keyword"
+          },
+          "type": {
+            "type": "keyword"
+          },
+          "value": {
+            "copy_to": "_all",
+            "type": "text",
+            "fields": {
+              "keyword": {
+                "type": "keyword",
+                "ignore_above": 256
+              }
+            }
+          }
+        }
       }
     }
   }
@@ -38,7 +59,34 @@ const historyMapping = `
     "properties": {
       "date": {"type": "keyword" }, 
       "filename": { "type": "keyword" }, 
-      "status": { "type": "integer" }
+      "status": { "type": "integer" },
+      "encoding": { "type": "keyword" },
+      "tags": { "type": "keyword" },
+      "report": { "type": "object", "enabled": false }
+    }
+  }
+}
+`
+
+const jobMapping = `
+{
+  "settings": {
+    "number_of_shards": 1,
+    "number_of_replicas": 0
+  },
+  "mappings": {
+    "properties": {
+      "type": { "type": "keyword" },
+      "date": { "type": "keyword" },
+      "checksum": { "type": "keyword" },
+      "filename": { "type": "keyword" },
+      "path": { "type": "keyword" },
+      "source": { "type": "keyword" },
+      "offset": { "type": "long" },
+      "config": { "type": "object", "enabled": false },
+      "report": { "type": "object", "enabled": false },
+      "members": { "type": "object", "enabled": false },
+      "tags": { "type": "keyword" }
     }
   }
 }
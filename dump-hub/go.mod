@@ -1,10 +1,18 @@
 module github.com/x0e1f/dump-hub
 
-go 1.16
+go 1.18
 
 require (
 	github.com/google/uuid v1.2.0
 	github.com/gorilla/mux v1.8.0
-	github.com/mailru/easyjson v0.7.7 // indirect
+	github.com/klauspost/compress v1.16.7
 	github.com/olivere/elastic/v7 v7.0.22
+	github.com/ulikunitz/xz v0.5.15
+	golang.org/x/text v0.14.0
+)
+
+require (
+	github.com/josharian/intern v1.0.0 // indirect
+	github.com/mailru/easyjson v0.7.7 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
 )
@@ -25,6 +25,9 @@ OTHER DEALINGS IN THE SOFTWARE.
 
 import (
 	"fmt"
+	"log"
+	"os"
+	"strconv"
 
 	"github.com/x0e1f/dump-hub/api"
 	"github.com/x0e1f/dump-hub/common"
@@ -44,7 +47,39 @@ func main() {
 		common.Port,
 		common.BaseAPI,
 		eClient,
+		envInt("DH_MAX_JOBS", common.MaxJobs),
+		envInt("DH_JOB_WORKERS", common.JobWorkers),
+		envString("DH_WATCH_DIR", common.WatchDir),
+		envString("DH_IMPORT_DIR", common.ImportDir),
 	)
 
 	engine.Serve()
 }
+
+/*
+envString :: Read setting from environment, value if not set
+*/
+func envString(name string, value string) string {
+	if env, ok := os.LookupEnv(name); ok {
+		return env
+	}
+
+	return value
+}
+
+/*
+envInt :: Read integer setting from environment, value if not set
+*/
+func envInt(name string, value int) int {
+	env := os.Getenv(name)
+	if len(env) < 1 {
+		return value
+	}
+
+	n, err := strconv.Atoi(env)
+	if err != nil {
+		log.Fatalf("Invalid %s value: %s", name, env)
+	}
+
+	return n
+}
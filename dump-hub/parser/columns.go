@@ -0,0 +1,343 @@
+package parser
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/x0e1f/dump-hub/common"
+)
+
+/*
+fieldTypes :: Supported semantic field types
+*/
+var fieldTypes = map[string]bool{
+	"email":    true,
+	"username": true,
+	"password": true,
+	"hash":     true,
+	"ip":       true,
+	"phone":    true,
+}
+
+/*
+column :: Selected column, written as selector[:name[:type]]
+*/
+type column struct {
+	selector string
+	index    int
+	name     string
+	kind     string
+}
+
+/*
+parseColumns :: Parse comma separated column specs. The selector is a
+column index or name (header, JSON path, SQL column, regex group).
+Name defaults to the selector, type is optional.
+*/
+func parseColumns(value string) ([]*column, error) {
+	columns := []*column{}
+	if len(strings.TrimSpace(value)) < 1 {
+		return columns, nil
+	}
+
+	for _, spec := range strings.Split(value, ",") {
+		parts := strings.SplitN(spec, ":", 3)
+		for i := range parts {
+			parts[i] = strings.TrimSpace(parts[i])
+		}
+
+		c := &column{
+			selector: parts[0],
+			index:    -1,
+			name:     parts[0],
+		}
+		if len(c.selector) < 1 {
+			return nil, fmt.Errorf("invalid column: %q", spec)
+		}
+		if index, err := strconv.Atoi(c.selector); err == nil && index >= 0 {
+			c.index = index
+		}
+		if len(parts) > 1 && len(parts[1]) > 0 {
+			c.name = parts[1]
+		}
+		if len(parts) > 2 && len(parts[2]) > 0 {
+			c.kind = strings.ToLower(parts[2])
+			if !fieldTypes[c.kind] {
+				return nil, fmt.Errorf("invalid column type %q, expected one of: %s", parts[2], typeNames())
+			}
+		}
+		c.name = fieldName(c.name)
+
+		columns = append(columns, c)
+	}
+
+	err := checkNames(columns)
+	if err != nil {
+		return nil, err
+	}
+
+	return columns, nil
+}
+
+/*
+checkNames :: Check that every selected column has its own name, a
+repeated name would overwrite the value of the previous column
+*/
+func checkNames(columns []*column) error {
+	names := map[string]bool{}
+	for _, c := range columns {
+		if names[c.name] {
+			return fmt.Errorf("duplicate column name: %q", c.name)
+		}
+		names[c.name] = true
+	}
+
+	return nil
+}
+
+/*
+setColumns :: Set selected columns, positional formats require indexes
+*/
+func (p *Parser) setColumns(value string, positional bool) error {
+	columns, err := parseColumns(value)
+	if err != nil {
+		return err
+	}
+	if !positional {
+		p.columns = columns
+		return nil
+	}
+
+	if len(columns) < 1 {
+		return errors.New("columns value not found")
+	}
+	for _, c := range columns {
+		if c.index < 0 {
+			return fmt.Errorf("invalid column index: %q", c.selector)
+		}
+	}
+	p.columns = columns
+
+	return nil
+}
+
+/*
+withHeader :: Copy of the parser selecting columns of a header row.
+Index selectors always select the column at that position, other
+selectors select the column with that header name (case is ignored if
+there is no exact match). Repeated header names get a suffix with
+their occurrence (a, a_2) and unnamed columns are named by index.
+Unnamed selected columns take the header name, without selection
+every column is kept
+*/
+func (p *Parser) withHeader(header []string) (*Parser, error) {
+	names := headerNames(header)
+
+	columns := []*column{}
+	if len(p.columns) < 1 {
+		for i, name := range names {
+			columns = append(columns, &column{
+				selector: name,
+				index:    i,
+				name:     fieldName(name),
+			})
+		}
+	}
+	for _, c := range p.columns {
+		resolved := *c
+		if c.index < 0 {
+			resolved.index = headerIndex(names, c.selector)
+		}
+		if resolved.index < 0 {
+			return nil, fmt.Errorf("column %q not found in header", c.selector)
+		}
+		if c.name == fieldName(c.selector) && resolved.index < len(names) {
+			resolved.name = fieldName(names[resolved.index])
+		}
+		columns = append(columns, &resolved)
+	}
+	err := checkNames(columns)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := *p
+	parser.columns = columns
+	parser.header = false
+
+	return &parser, nil
+}
+
+/*
+headerNames :: Trimmed names of a header row, unnamed columns are
+named by index and repeated names get a suffix with their occurrence
+*/
+func headerNames(header []string) []string {
+	names := make([]string, len(header))
+	used := map[string]bool{}
+	for i := range header {
+		name := strings.TrimSpace(header[i])
+		if len(name) < 1 {
+			name = strconv.Itoa(i)
+		}
+		unique := name
+		for n := 2; used[fieldName(unique)]; n++ {
+			unique = name + "_" + strconv.Itoa(n)
+		}
+		used[fieldName(unique)] = true
+		names[i] = unique
+	}
+
+	return names
+}
+
+/*
+headerIndex :: Index of a header name, case is ignored if there is
+no exact match. -1 if not found
+*/
+func headerIndex(names []string, selector string) int {
+	for i, name := range names {
+		if name == selector || fieldName(name) == selector {
+			return i
+		}
+	}
+	for i, name := range names {
+		if strings.EqualFold(name, selector) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+/*
+newEntry :: Create empty entry document
+*/
+func newEntry(filename string, checkSum string) *common.Entry {
+	return &common.Entry{
+		Origin:   filename,
+		OriginID: checkSum,
+		Fields:   map[string]string{},
+	}
+}
+
+/*
+setField :: Set named field value (and its semantic type)
+*/
+func setField(entry *common.Entry, c *column, name string, value string) {
+	entry.Fields[name] = value
+	if c == nil || len(c.kind) < 1 {
+		return
+	}
+
+	if entry.Types == nil {
+		entry.Types = map[string]string{}
+	}
+	entry.Types[name] = c.kind
+}
+
+/*
+positionalEntry :: Build entry document from selected column indexes,
+nil if none of the selected columns is found
+*/
+func (p *Parser) positionalEntry(filename string, checkSum string, values []string) *common.Entry {
+	entry := newEntry(filename, checkSum)
+
+	for _, c := range p.columns {
+		if c.index >= len(values) || len(values[c.index]) < 1 {
+			continue
+		}
+		setField(entry, c, c.name, values[c.index])
+	}
+	if len(entry.Fields) < 1 {
+		return nil
+	}
+
+	return entry
+}
+
+/*
+namedEntry :: Build entry document from named columns, columns are
+selected by name or by index. Without selection every column is kept.
+*/
+func (p *Parser) namedEntry(filename string, checkSum string, names []string, values []*string) *common.Entry {
+	entry := newEntry(filename, checkSum)
+
+	for i, value := range values {
+		if value == nil || len(*value) < 1 {
+			continue
+		}
+
+		name := columnName(names, i)
+		if len(p.columns) < 1 {
+			setField(entry, nil, fieldName(name), *value)
+			continue
+		}
+		for _, c := range p.columns {
+			if c.index == i || c.selector == name {
+				setField(entry, c, c.name, *value)
+			}
+		}
+	}
+	if len(entry.Fields) < 1 {
+		return nil
+	}
+
+	return entry
+}
+
+/*
+columnName :: Column name, falls back to the column index
+*/
+func columnName(names []string, i int) string {
+	if i < len(names) && len(names[i]) > 0 {
+		return names[i]
+	}
+	return strconv.Itoa(i)
+}
+
+/*
+fieldName :: Elasticsearch safe field name, dots would create objects
+*/
+func fieldName(name string) string {
+	return strings.Replace(name, ".", "_", -1)
+}
+
+/*
+typeNames :: Sorted list of supported field types
+*/
+func typeNames() string {
+	names := []string{}
+	for name := range fieldTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
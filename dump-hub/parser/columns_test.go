@@ -0,0 +1,186 @@
+package parser
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/x0e1f/dump-hub/common"
+)
+
+func TestParseHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  common.ParserConfig
+		input   string
+		entries []map[string]string
+		skipped []string
+	}{
+		{
+			name:   "header after comment lines",
+			config: common.ParserConfig{Format: FormatCSV, Pattern: "{,}{#}", Columns: "Email:email:email,2"},
+			input:  "# users\n  # export\nEmail,Password,IP\na@b.c,\"x,y\",1.1.1.1\nc@d.e,z,2.2.2.2\n",
+			entries: []map[string]string{
+				{"email": "a@b.c", "IP": "1.1.1.1"},
+				{"email": "c@d.e", "IP": "2.2.2.2"},
+			},
+			skipped: []string{ReasonComment, ReasonComment, ReasonHeader},
+		},
+		{
+			name:    "header after empty lines",
+			config:  common.ParserConfig{Format: FormatText, Pattern: "{:}{}", Columns: "email,password"},
+			input:   "\n\nemail:password\na@b.c:pw\n",
+			entries: []map[string]string{{"email": "a@b.c", "password": "pw"}},
+			skipped: []string{ReasonEmpty, ReasonEmpty, ReasonHeader},
+		},
+		{
+			name:    "case insensitive match",
+			config:  common.ParserConfig{Format: FormatText, Pattern: "{;}{}", Columns: "EMAIL:mail,pass"},
+			input:   "Email ; Pass\na;b\n",
+			entries: []map[string]string{{"mail": "a", "Pass": "b"}},
+			skipped: []string{ReasonHeader},
+		},
+		{
+			name:    "exact match first",
+			config:  common.ParserConfig{Format: FormatCSV, Pattern: "{,}{}", Columns: "EMAIL"},
+			input:   "email,EMAIL\na,b\n",
+			entries: []map[string]string{{"EMAIL": "b"}},
+			skipped: []string{ReasonHeader},
+		},
+		{
+			name:    "dotted names",
+			config:  common.ParserConfig{Format: FormatCSV, Pattern: "{,}{}", Columns: "user_email,1:pw"},
+			input:   "user.email,password\na,b\n",
+			entries: []map[string]string{{"user_email": "a", "pw": "b"}},
+			skipped: []string{ReasonHeader},
+		},
+		{
+			name:    "every column without selection",
+			config:  common.ParserConfig{Format: FormatCSV, Pattern: "{,}{}"},
+			input:   "a,,c\n1,2,3\n",
+			entries: []map[string]string{{"a": "1", "1": "2", "c": "3"}},
+			skipped: []string{ReasonHeader},
+		},
+		{
+			name:    "repeated names",
+			config:  common.ParserConfig{Format: FormatCSV, Pattern: "{,}{}"},
+			input:   "a,a,b,a\n1,2,3,4\n",
+			entries: []map[string]string{{"a": "1", "a_2": "2", "b": "3", "a_3": "4"}},
+			skipped: []string{ReasonHeader},
+		},
+		{
+			name:    "repeated name selectors",
+			config:  common.ParserConfig{Format: FormatCSV, Pattern: "{,}{}", Columns: "a:first,a_2:second"},
+			input:   "a,a,b\n1,2,3\n",
+			entries: []map[string]string{{"first": "1", "second": "2"}},
+			skipped: []string{ReasonHeader},
+		},
+		{
+			name:    "renamed name taken by a later column",
+			config:  common.ParserConfig{Format: FormatCSV, Pattern: "{,}{}"},
+			input:   "a,a,a_2\n1,2,3\n",
+			entries: []map[string]string{{"a": "1", "a_2": "2", "a_2_2": "3"}},
+			skipped: []string{ReasonHeader},
+		},
+		{
+			name:    "index selectors before header names",
+			config:  common.ParserConfig{Format: FormatCSV, Pattern: "{,}{}", Columns: "0:first,1"},
+			input:   "x,0\n1,2\n",
+			entries: []map[string]string{{"first": "1", "0": "2"}},
+			skipped: []string{ReasonHeader},
+		},
+		{
+			name:    "header only",
+			config:  common.ParserConfig{Format: FormatText, Pattern: "{:}{}", Columns: "email"},
+			input:   "email:password\n",
+			skipped: []string{ReasonHeader},
+		},
+	}
+
+	for _, test := range tests {
+		config := test.config
+		config.Header = true
+		got, err := parseString(t, &config, test.input)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		checkResult(t, test.name, got, test.entries, test.skipped)
+	}
+}
+
+func TestParseHeaderMissingColumn(t *testing.T) {
+	for _, format := range []string{FormatText, FormatCSV} {
+		_, err := parseString(t, &common.ParserConfig{
+			Format:  format,
+			Pattern: "{,}{#}",
+			Columns: "email,phone",
+			Header:  true,
+		}, "# users\nemail,password\na,b\n")
+		if err == nil || !strings.Contains(err.Error(), `"phone"`) {
+			t.Errorf("%s: got %v, want missing column error", format, err)
+		}
+	}
+}
+
+func TestHeaderConfig(t *testing.T) {
+	invalid := []common.ParserConfig{
+		{Format: FormatText, Pattern: "{:}{}", Columns: "email"},
+		{Format: FormatJSON, Header: true},
+		{Format: FormatSQL, Header: true},
+		{Format: FormatRegex, Regex: `(?P<a>\w+)`, Header: true},
+	}
+	for _, config := range invalid {
+		if _, err := New(&config); err == nil {
+			t.Errorf("%+v: expected an error", config)
+		}
+	}
+}
+
+func TestDuplicateColumnNames(t *testing.T) {
+	invalid := []common.ParserConfig{
+		{Format: FormatText, Pattern: "{:}{}", Columns: "0:x,1:x"},
+		{Format: FormatCSV, Pattern: "{,}{}", Columns: "0,1:0"},
+		{Format: FormatJSON, Columns: "a.b,a_b"},
+		{Format: FormatRegex, Regex: `(?P<a>\w+):(?P<b>\w+)`, Columns: "a:x,b:x"},
+	}
+	for _, config := range invalid {
+		_, err := New(&config)
+		if err == nil || !strings.Contains(err.Error(), "duplicate column name") {
+			t.Errorf("%+v: got %v, want duplicate name error", config, err)
+		}
+	}
+
+	/* Header names are known only when the header is read */
+	_, err := parseString(t, &common.ParserConfig{
+		Format:  FormatCSV,
+		Pattern: "{,}{}",
+		Columns: "0,a",
+		Header:  true,
+	}, "a,b\n1,2\n")
+	if err == nil || !strings.Contains(err.Error(), `duplicate column name: "a"`) {
+		t.Errorf("header: got %v, want duplicate name error", err)
+	}
+}
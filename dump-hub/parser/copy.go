@@ -0,0 +1,240 @@
+package parser
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/x0e1f/dump-hub/common"
+)
+
+/*
+copyHeader :: COPY table (columns) FROM stdin; block header
+*/
+var copyHeader = regexp.MustCompile(
+	`(?i)^COPY\s+((?:"[^"]+"|[\w$]+)(?:\.(?:"[^"]+"|[\w$]+))?)\s*(?:\(([^)]*)\))?\s+FROM\s+stdin`,
+)
+
+/*
+copyBlock :: Rows of a PostgreSQL COPY block
+*/
+type copyBlock struct {
+	table   string
+	columns []string
+}
+
+/*
+parseCopy :: Parse rows of the selected COPY block
+*/
+func (p *Parser) parseCopy(r io.Reader, filename string, checkSum string, handler func(*common.Entry), skip func(*Skipped)) error {
+	table := p.table
+	lines := p.newLineReader(bufio.NewReader(r), skip)
+
+	return scanCopy(lines, func(block *copyBlock, line string, values []*string) {
+		/* Without a selected table use the first block found */
+		if len(table) < 1 {
+			table = block.table
+		}
+		if !tableMatch(block.table, table) {
+			return
+		}
+
+		entry := p.namedEntry(filename, checkSum, block.columns, values)
+		if entry == nil {
+			lines.skipLine(line, ReasonNoFields)
+			return
+		}
+		handler(entry)
+	})
+}
+
+/*
+scanCopy :: Scan COPY blocks, everything outside them is ignored
+*/
+func scanCopy(lines *lineReader, handler func(*copyBlock, string, []*string)) error {
+	var block *copyBlock
+
+	for {
+		line, err := lines.readLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case block == nil:
+			block = parseCopyHeader(line)
+		case line == `\.`:
+			block = nil
+		default:
+			handler(block, line, decodeCopyRow(line))
+		}
+	}
+}
+
+/*
+parseCopyHeader :: Parse COPY header line, nil if not a header
+*/
+func parseCopyHeader(line string) *copyBlock {
+	matches := copyHeader.FindStringSubmatch(line)
+	if matches == nil {
+		return nil
+	}
+
+	block := &copyBlock{
+		table:   strings.Replace(matches[1], `"`, "", -1),
+		columns: []string{},
+	}
+	if len(strings.TrimSpace(matches[2])) > 0 {
+		for _, column := range strings.Split(matches[2], ",") {
+			column = strings.TrimSpace(column)
+			column = strings.Trim(column, `"`)
+			block.columns = append(block.columns, column)
+		}
+	}
+
+	return block
+}
+
+/*
+decodeCopyRow :: Split tab separated row and decode COPY escapes,
+\N values are NULL
+*/
+func decodeCopyRow(line string) []*string {
+	fields := strings.Split(line, "\t")
+	values := make([]*string, len(fields))
+
+	for i, field := range fields {
+		if field == `\N` {
+			continue
+		}
+		value := decodeCopyField(field)
+		values[i] = &value
+	}
+
+	return values
+}
+
+/*
+decodeCopyField :: Decode backslash sequences of a COPY field
+*/
+func decodeCopyField(field string) string {
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+
+	value := strings.Builder{}
+	for i := 0; i < len(field); i++ {
+		c := field[i]
+		if c != '\\' || i+1 >= len(field) {
+			value.WriteByte(c)
+			continue
+		}
+
+		i++
+		switch c = field[i]; c {
+		case 'b':
+			value.WriteByte('\b')
+		case 'f':
+			value.WriteByte('\f')
+		case 'n':
+			value.WriteByte('\n')
+		case 'r':
+			value.WriteByte('\r')
+		case 't':
+			value.WriteByte('\t')
+		case 'v':
+			value.WriteByte('\v')
+		case 'x':
+			/* \xH or \xHH */
+			end := i + 1
+			for end < len(field) && end < i+3 && isHex(field[end]) {
+				end++
+			}
+			if end == i+1 {
+				value.WriteByte(c)
+				continue
+			}
+			n, _ := strconv.ParseUint(field[i+1:end], 16, 8)
+			value.WriteByte(byte(n))
+			i = end - 1
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			/* \N, \NN or \NNN octal */
+			end := i + 1
+			for end < len(field) && end < i+3 && field[end] >= '0' && field[end] <= '7' {
+				end++
+			}
+			n, _ := strconv.ParseUint(field[i:end], 8, 16)
+			value.WriteByte(byte(n))
+			i = end - 1
+		default:
+			value.WriteByte(c)
+		}
+	}
+
+	return value.String()
+}
+
+/*
+isHex :: Check if byte is a hexadecimal digit
+*/
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+/*
+copyTables :: Collect COPY blocks and a few sample rows
+*/
+func (p *Parser) copyTables(r io.Reader, maxRows int) ([]*common.Table, error) {
+	tables := []*common.Table{}
+	index := map[string]*common.Table{}
+	lines := p.newLineReader(bufio.NewReader(r), nil)
+
+	err := scanCopy(lines, func(block *copyBlock, line string, values []*string) {
+		table, ok := index[block.table]
+		if !ok {
+			table = &common.Table{
+				Name:    block.table,
+				Columns: block.columns,
+				Rows:    [][]string{},
+			}
+			index[block.table] = table
+			tables = append(tables, table)
+		}
+		if len(table.Rows) < maxRows {
+			table.Rows = append(table.Rows, rowValues(values))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tables, nil
+}
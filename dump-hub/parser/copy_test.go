@@ -0,0 +1,99 @@
+package parser
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"testing"
+
+	"github.com/x0e1f/dump-hub/common"
+)
+
+func TestParseCopy(t *testing.T) {
+	users := "COPY public.users (id, \"e mail\", password) FROM stdin;\n" +
+		"1\ta@b.c\tpw\n" +
+		"2\t\\N\t\n" +
+		"\\.\n"
+	tests := []struct {
+		name    string
+		config  common.ParserConfig
+		input   string
+		entries []map[string]string
+		skipped []string
+	}{
+		{
+			name:  "block",
+			input: "SET client_encoding = 'UTF8';\n" + users + "SELECT 1;\n",
+			entries: []map[string]string{
+				{"id": "1", "e mail": "a@b.c", "password": "pw"},
+				{"id": "2"},
+			},
+		},
+		{
+			name:    "selected columns",
+			config:  common.ParserConfig{Columns: "e mail:email:email,2"},
+			input:   users,
+			entries: []map[string]string{{"email": "a@b.c", "2": "pw"}},
+			skipped: []string{ReasonNoFields},
+		},
+		{
+			name:    "columns by index",
+			input:   "copy t from STDIN;\na\tb\n\\.\n",
+			entries: []map[string]string{{"0": "a", "1": "b"}},
+		},
+		{
+			name:  "escapes",
+			input: "COPY t (a) FROM stdin;\n" + `x\ty\\z\nw\x41\101\7\q` + "\n\\.\n",
+			entries: []map[string]string{
+				{"a": "x\ty\\z\nwAA\x07q"},
+			},
+		},
+		{
+			name:    "first block by default",
+			input:   "COPY a (v) FROM stdin;\n1\n\\.\nCOPY b (v) FROM stdin;\n2\n\\.\n",
+			entries: []map[string]string{{"v": "1"}},
+		},
+		{
+			name:    "selected table",
+			config:  common.ParserConfig{Table: "users"},
+			input:   "COPY a (v) FROM stdin;\n1\n\\.\n" + users,
+			entries: []map[string]string{{"id": "1", "e mail": "a@b.c", "password": "pw"}, {"id": "2"}},
+		},
+		{
+			name:    "lines outside blocks",
+			input:   "INSERT INTO t VALUES ('a');\nx\ty\n",
+			entries: nil,
+		},
+	}
+
+	for _, test := range tests {
+		config := test.config
+		config.Format = FormatCopy
+		got, err := parseString(t, &config, test.input)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		checkResult(t, test.name, got, test.entries, test.skipped)
+	}
+}
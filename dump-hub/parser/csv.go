@@ -0,0 +1,235 @@
+package parser
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/x0e1f/dump-hub/common"
+)
+
+/*
+csvReader :: RFC 4180 record reader with configurable quote and escape,
+records spanning multiple lines are at most maxLength bytes long.
+Pending is a line read past a rejected record, returned next
+*/
+type csvReader struct {
+	lines       *lineReader
+	separator   string
+	commentChar string
+	quote       rune
+	escape      rune
+	maxLength   int
+	pending     *string
+}
+
+/*
+parseCSV :: Parse CSV records from reader
+*/
+func (p *Parser) parseCSV(r io.Reader, filename string, checkSum string, handler func(*common.Entry), skip func(*Skipped)) error {
+	c := &csvReader{
+		lines:       p.newLineReader(bufio.NewReader(r), skip),
+		separator:   p.separator,
+		commentChar: p.commentChar,
+		quote:       p.quote,
+		escape:      p.escape,
+		maxLength:   p.maxLine,
+	}
+
+	parser := p
+	for {
+		record, err := c.readRecord()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			continue
+		}
+
+		/* First record names the columns */
+		if parser.header {
+			parser, err = p.withHeader(record)
+			if err != nil {
+				return err
+			}
+			c.lines.skipLine(strings.Join(record, c.separator), ReasonHeader)
+			continue
+		}
+
+		entry := parser.positionalEntry(filename, checkSum, record)
+		if entry == nil {
+			c.lines.skipLine(strings.Join(record, c.separator), ReasonNoFields)
+			continue
+		}
+		handler(entry)
+	}
+}
+
+/*
+readLine :: Read a single line without line terminator
+*/
+func (c *csvReader) readLine() (string, error) {
+	if c.pending != nil {
+		line := *c.pending
+		c.pending = nil
+		return line, nil
+	}
+
+	return c.lines.readLine()
+}
+
+/*
+unterminated :: Report a record with an unterminated quoted field
+*/
+func (c *csvReader) unterminated(line int, raw string) {
+	c.lines.skip(&Skipped{
+		Line:   line,
+		Raw:    raw,
+		Reason: ReasonUnterminated,
+	})
+}
+
+/*
+readRecord :: Read the next record, quoted fields may span multiple lines.
+Returns a nil record for empty and comment lines and for records with
+an unterminated quoted field, the line that would make such a record
+longer than the maximum length is read again as a new record.
+*/
+func (c *csvReader) readRecord() ([]string, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+
+	/* Skip empty and comment lines */
+	trimmed := strings.TrimLeftFunc(line, unicode.IsSpace)
+	if len(trimmed) < 1 {
+		c.lines.skipLine(line, ReasonEmpty)
+		return nil, nil
+	}
+	if len(c.commentChar) > 0 && strings.HasPrefix(trimmed, c.commentChar) {
+		c.lines.skipLine(line, ReasonComment)
+		return nil, nil
+	}
+
+	start := c.lines.line
+	raw := strings.Builder{}
+	raw.WriteString(line)
+
+	record := []string{}
+	field := strings.Builder{}
+	quoted := false
+	fieldStart := true
+
+	for i := 0; ; {
+		/* End of line */
+		if i >= len(line) {
+			if !quoted {
+				break
+			}
+
+			/* Quoted field continues on next line */
+			next, err := c.readLine()
+			if err == io.EOF {
+				c.unterminated(start, raw.String())
+				return nil, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			if raw.Len()+1+len(next) > c.maxLength {
+				c.pending = &next
+				c.unterminated(start, raw.String())
+				return nil, nil
+			}
+			raw.WriteByte('\n')
+			raw.WriteString(next)
+			field.WriteByte('\n')
+			line = next
+			i = 0
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(line[i:])
+
+		if quoted {
+			switch {
+			case r == c.escape && c.escape != c.quote:
+				/* Escaped character */
+				i += size
+				if i < len(line) {
+					next, nextSize := utf8.DecodeRuneInString(line[i:])
+					field.WriteRune(next)
+					i += nextSize
+				}
+			case r == c.quote:
+				/* Doubled quote or end of quoted section */
+				i += size
+				if c.escape == c.quote && strings.HasPrefix(line[i:], string(c.quote)) {
+					field.WriteRune(c.quote)
+					i += size
+					continue
+				}
+				quoted = false
+			default:
+				field.WriteRune(r)
+				i += size
+			}
+			continue
+		}
+
+		/* Field separator */
+		if strings.HasPrefix(line[i:], c.separator) {
+			record = append(record, field.String())
+			field.Reset()
+			fieldStart = true
+			i += len(c.separator)
+			continue
+		}
+
+		/* Opening quote, leading blanks are discarded */
+		if r == c.quote && fieldStart {
+			field.Reset()
+			quoted = true
+			i += size
+			continue
+		}
+		if !unicode.IsSpace(r) {
+			fieldStart = false
+		}
+
+		field.WriteRune(r)
+		i += size
+	}
+	record = append(record, field.String())
+
+	return record, nil
+}
@@ -0,0 +1,133 @@
+package parser
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/x0e1f/dump-hub/common"
+)
+
+func TestParseCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  common.ParserConfig
+		input   string
+		entries []map[string]string
+		skipped []string
+	}{
+		{
+			name:    "quoted separator",
+			config:  common.ParserConfig{Pattern: "{,}{#}", Columns: "0,1"},
+			input:   "a@b.c,\"x,y\"\n",
+			entries: []map[string]string{{"0": "a@b.c", "1": "x,y"}},
+		},
+		{
+			name:    "doubled quote",
+			config:  common.ParserConfig{Pattern: "{,}{#}", Columns: "1"},
+			input:   "a,\"say \"\"hi\"\"\"\n",
+			entries: []map[string]string{{"1": `say "hi"`}},
+		},
+		{
+			name:    "escape character",
+			config:  common.ParserConfig{Pattern: "{;}{}", Columns: "0", Escape: `\`},
+			input:   "\"a\\\"b;c\"\n",
+			entries: []map[string]string{{"0": `a"b;c`}},
+		},
+		{
+			name:    "custom quote",
+			config:  common.ParserConfig{Pattern: "{,}{}", Columns: "0,1", Quote: "'"},
+			input:   "'a,b',c\n",
+			entries: []map[string]string{{"0": "a,b", "1": "c"}},
+		},
+		{
+			name:    "multi-line field",
+			config:  common.ParserConfig{Pattern: "{,}{#}", Columns: "0,1"},
+			input:   "a,\"line 1\nline 2\"\nb,c\n",
+			entries: []map[string]string{{"0": "a", "1": "line 1\nline 2"}, {"0": "b", "1": "c"}},
+		},
+		{
+			name:    "comment and empty lines",
+			config:  common.ParserConfig{Pattern: "{,}{#}", Columns: "0"},
+			input:   "# dump\n\n  # indented\na,b\n",
+			entries: []map[string]string{{"0": "a"}},
+			skipped: []string{ReasonComment, ReasonEmpty, ReasonComment},
+		},
+		{
+			name:    "no selected fields",
+			config:  common.ParserConfig{Pattern: "{,}{#}", Columns: "2"},
+			input:   "a,b\na,b,c\n",
+			entries: []map[string]string{{"2": "c"}},
+			skipped: []string{ReasonNoFields},
+		},
+		{
+			name:    "unterminated at end of file",
+			config:  common.ParserConfig{Pattern: "{,}{#}", Columns: "0,1"},
+			input:   "a,b\nc,\"d\ne,f\ng,h\n",
+			entries: []map[string]string{{"0": "a", "1": "b"}},
+			skipped: []string{ReasonUnterminated},
+		},
+		{
+			name:    "unterminated longer than max line",
+			config:  common.ParserConfig{Pattern: "{,}{#}", Columns: "0,1", MaxLine: 10},
+			input:   "a,\"b\ncccc\ndddd\ne,f\n",
+			entries: []map[string]string{{"0": "dddd"}, {"0": "e", "1": "f"}},
+			skipped: []string{ReasonUnterminated},
+		},
+	}
+
+	for _, test := range tests {
+		config := test.config
+		config.Format = FormatCSV
+		got, err := parseString(t, &config, test.input)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		checkResult(t, test.name, got, test.entries, test.skipped)
+	}
+}
+
+func TestParseCSVUnterminatedLines(t *testing.T) {
+	/* A stray quote does not swallow the rest of the file */
+	input := "a,b\n\"c,d\n" + strings.Repeat("e,f\n", 100)
+	got, err := parseString(t, &common.ParserConfig{
+		Format:  FormatCSV,
+		Pattern: "{,}{}",
+		Columns: "0,1",
+		MaxLine: 64,
+	}, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got.skipped) != 1 || got.skipped[0] != ReasonUnterminated || got.lines[0] != 2 {
+		t.Fatalf("skipped: got %v at lines %v", got.skipped, got.lines)
+	}
+	/* The record stops at 64 bytes, after 15 lines following the quote */
+	if len(got.entries) != 86 {
+		t.Errorf("entries: got %d, want 86", len(got.entries))
+	}
+}
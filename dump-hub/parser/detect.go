@@ -0,0 +1,505 @@
+package parser
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/x0e1f/dump-hub/common"
+)
+
+/* Rows of the sample used to guess column types */
+const maxDetectRows = 200
+
+/* Most columns of a delimited file */
+const maxDetectColumns = 64
+
+/* Share of the values of a column matching a field type */
+const typeShare = 0.8
+
+/* Separators tried by detection, in order of preference */
+var detectSeparators = []string{"\t", ",", ";", "|", ":"}
+
+/* Comment markers tried by detection */
+var detectComments = []string{"#", "//", "--", ";"}
+
+var (
+	emailValue  = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	hashValue   = regexp.MustCompile(`^(?:[[:xdigit:]]{32}|[[:xdigit:]]{40}|[[:xdigit:]]{56}|[[:xdigit:]]{64}|[[:xdigit:]]{96}|[[:xdigit:]]{128}|\$[0-9a-z-]+\$\S{8,})$`)
+	headerValue = regexp.MustCompile(`^[A-Za-z_][\w .-]{0,63}$`)
+	sqlLine     = regexp.MustCompile(`(?i)^\s*(?:INSERT|REPLACE)\s+INTO\b|^\s*CREATE\s+TABLE\b`)
+)
+
+/*
+Detect :: Guess parser settings from a file sample (UTF-8 text)
+*/
+func Detect(sample []byte) (*common.Detection, error) {
+	lines := sampleLines(sample)
+	if len(lines) < 1 {
+		return nil, errors.New("empty sample")
+	}
+
+	for _, line := range lines {
+		if copyHeader.MatchString(line) {
+			return detectTables(FormatCopy, sample)
+		}
+	}
+	for _, line := range lines {
+		if sqlLine.MatchString(line) {
+			return detectTables(FormatSQL, sample)
+		}
+	}
+
+	/* Lines starting like JSON may be text as well */
+	if first := lines[0]; strings.HasPrefix(first, "[") || strings.HasPrefix(first, "{") {
+		detection, err := detectJSON(sample, lines)
+		if err == nil {
+			return detection, nil
+		}
+	}
+
+	return detectDelimited(sample, lines)
+}
+
+/*
+sampleLines :: Non empty lines of a sample, without surrounding spaces
+*/
+func sampleLines(sample []byte) []string {
+	lines := []string{}
+	for _, line := range strings.Split(string(sample), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines
+}
+
+/*
+detectTables :: Guess settings of a database dump, the table with
+more rows in the sample is selected
+*/
+func detectTables(format string, sample []byte) (*common.Detection, error) {
+	p, err := New(&common.ParserConfig{Format: format})
+	if err != nil {
+		return nil, err
+	}
+	tables, err := p.Tables(bytes.NewReader(sample))
+	if err != nil {
+		return nil, err
+	}
+
+	var table *common.Table
+	for _, t := range tables {
+		if table == nil || len(t.Rows) > len(table.Rows) {
+			table = t
+		}
+	}
+	if table == nil {
+		return nil, errors.New("no tables found")
+	}
+
+	/* Columns without names are selected by index */
+	width := len(table.Columns)
+	for _, row := range table.Rows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+	selectors := make([]string, width)
+	for i := range selectors {
+		selectors[i] = columnName(table.Columns, i)
+	}
+	columns := detectColumns(selectors, table.Rows)
+
+	return &common.Detection{
+		Kind: format,
+		Config: &common.ParserConfig{
+			Format:  format,
+			Table:   table.Name,
+			Columns: columnSpecs(columns, false),
+		},
+		Columns: columns,
+	}, nil
+}
+
+/*
+detectJSON :: Guess settings of JSON Lines or of a JSON array, nested
+keys are selected by path
+*/
+func detectJSON(sample []byte, lines []string) (*common.Detection, error) {
+	records := []map[string]string{}
+	add := func(record interface{}) {
+		if _, ok := record.(map[string]interface{}); !ok {
+			return
+		}
+		flat := map[string]string{}
+		flatten("", record, flat)
+		records = append(records, flat)
+	}
+
+	if strings.HasPrefix(lines[0], "[") {
+		decoder := json.NewDecoder(bytes.NewReader(sample))
+		_, err := decoder.Token()
+		for err == nil && decoder.More() && len(records) < maxDetectRows {
+			var record interface{}
+			err = decoder.Decode(&record)
+			if err == nil {
+				add(record)
+			}
+		}
+	} else {
+		for _, line := range lines {
+			var record interface{}
+			if json.Unmarshal([]byte(line), &record) == nil {
+				add(record)
+			}
+			if len(records) >= maxDetectRows {
+				break
+			}
+		}
+	}
+	if len(records) < 1 {
+		return nil, errors.New("no JSON records found")
+	}
+
+	found := map[string]bool{}
+	keys := []string{}
+	for _, record := range records {
+		for key := range record {
+			if !found[key] {
+				found[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	rows := [][]string{}
+	for _, record := range records {
+		row := make([]string, len(keys))
+		for i, key := range keys {
+			row[i] = record[key]
+		}
+		rows = append(rows, row)
+	}
+	columns := detectColumns(keys, rows)
+
+	return &common.Detection{
+		Kind: FormatJSON,
+		Config: &common.ParserConfig{
+			Format:  FormatJSON,
+			Columns: columnSpecs(columns, false),
+		},
+		Columns: columns,
+	}, nil
+}
+
+/*
+detectDelimited :: Guess comment marker, separator and columns of a
+delimited text file. The separator splitting most lines in the same
+number of columns wins, that number of columns is selected
+*/
+func detectDelimited(sample []byte, lines []string) (*common.Detection, error) {
+	comment, comments := "", 0
+	for _, marker := range detectComments {
+		n := 0
+		for _, line := range lines {
+			if strings.HasPrefix(strings.Replace(line, " ", "", -1), marker) {
+				n++
+			}
+		}
+		if n > comments && n < len(lines) {
+			comment, comments = marker, n
+		}
+	}
+	data := []string{}
+	for _, line := range lines {
+		if len(comment) < 1 || !strings.HasPrefix(strings.Replace(line, " ", "", -1), comment) {
+			data = append(data, line)
+		}
+	}
+
+	separator, width, share := ":", 1, 0.0
+	for _, sep := range detectSeparators {
+		if sep == comment {
+			continue
+		}
+		counts := map[int]int{}
+		for _, line := range data {
+			counts[strings.Count(line, sep)+1]++
+		}
+		mode, n := 0, 0
+		for count, c := range counts {
+			if c > n || (c == n && count < mode) {
+				mode, n = count, c
+			}
+		}
+		if s := float64(n) / float64(len(data)); mode > 1 && s > share {
+			separator, width, share = sep, mode, s
+		}
+	}
+
+	/* Quoted fields require the CSV format */
+	format := FormatText
+	for _, line := range data {
+		if separator != ":" && strings.Contains(line, `"`) {
+			format = FormatCSV
+		}
+	}
+	if width > maxDetectColumns {
+		width = maxDetectColumns
+	}
+
+	/* Values are split by the parser itself */
+	selectors := make([]string, width)
+	for i := range selectors {
+		selectors[i] = strconv.Itoa(i)
+	}
+	config := &common.ParserConfig{
+		Format:  format,
+		Pattern: "{" + escapePattern(separator) + "}{" + escapePattern(comment) + "}",
+		Columns: strings.Join(selectors, ","),
+	}
+	p, err := New(config)
+	if err != nil {
+		return nil, err
+	}
+	rows := [][]string{}
+	p.Parse(bytes.NewReader(sample), "", "", func(entry *common.Entry) {
+		if len(rows) >= maxDetectRows {
+			return
+		}
+		row := make([]string, width)
+		for i := range row {
+			row[i] = entry.Fields[strconv.Itoa(i)]
+		}
+		rows = append(rows, row)
+	}, nil)
+	if len(rows) < 1 {
+		return nil, errors.New("no entries found")
+	}
+
+	/* Trailing columns without values are left out */
+	used := 1
+	for _, row := range rows {
+		for i, value := range row {
+			if len(value) > 0 && i >= used {
+				used = i + 1
+			}
+		}
+	}
+	selectors = selectors[:used]
+
+	header := isHeader(rows)
+	headerRow := rows[0]
+	if header {
+		rows = rows[1:]
+	}
+	columns := detectColumns(selectors, rows)
+	unique := headerNames(headerRow)
+	names := map[string]bool{}
+	for i := range columns {
+		if header && len(strings.TrimSpace(headerRow[i])) > 0 {
+			names[fieldName(unique[i])] = true
+		}
+	}
+	for i, c := range columns {
+		switch {
+		case header && len(strings.TrimSpace(headerRow[i])) > 0:
+			c.Selector = unique[i]
+			c.Name = unique[i]
+		case len(c.Type) > 0:
+			/* Typed columns are named by type, suffixed if taken */
+			c.Name = c.Type
+			if names[c.Name] {
+				c.Name = c.Type + "_" + c.Selector
+			}
+			for n := 2; names[c.Name]; n++ {
+				c.Name = c.Type + "_" + c.Selector + "_" + strconv.Itoa(n)
+			}
+		}
+		names[fieldName(c.Name)] = true
+	}
+	config.Columns = columnSpecs(columns, true)
+	config.Header = header
+	if format == FormatCSV {
+		config.Quote = `"`
+	}
+
+	kind := format
+	switch {
+	case format == FormatText && separator == ",":
+		kind = FormatCSV
+	case format == FormatText && len(columns) == 2 && (separator == ":" || separator == ";"):
+		kind = "combolist"
+	}
+
+	return &common.Detection{
+		Kind:    kind,
+		Config:  config,
+		Header:  header,
+		Columns: columns,
+	}, nil
+}
+
+/*
+escapePattern :: Escape a pattern group value
+*/
+func escapePattern(value string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		"{", `\{`,
+		"}", `\}`,
+		"\t", `\t`,
+	).Replace(value)
+}
+
+/*
+isHeader :: Check if the first row names the columns, names are words
+and at least a column has typed values in the other rows
+*/
+func isHeader(rows [][]string) bool {
+	if len(rows) < 2 {
+		return false
+	}
+	for _, value := range rows[0] {
+		if len(value) > 0 && (!headerValue.MatchString(value) || len(valueKind(value)) > 0) {
+			return false
+		}
+	}
+
+	for i := range rows[0] {
+		if len(rows[0][i]) > 0 && len(columnKind(rows[1:], i)) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+detectColumns :: Guess field type of every column
+*/
+func detectColumns(selectors []string, rows [][]string) []*common.DetectedColumn {
+	columns := []*common.DetectedColumn{}
+	for i, selector := range selectors {
+		c := &common.DetectedColumn{
+			Selector: selector,
+			Name:     selector,
+		}
+		if kind := columnKind(rows, i); fieldTypes[kind] {
+			c.Type = kind
+		}
+		for _, row := range rows {
+			if i < len(row) && len(row[i]) > 0 {
+				c.Sample = row[i]
+				break
+			}
+		}
+		columns = append(columns, c)
+	}
+
+	return columns
+}
+
+/*
+columnKind :: Kind of most values of a column, empty if none
+*/
+func columnKind(rows [][]string, i int) string {
+	kinds := map[string]int{}
+	total := 0
+	for _, row := range rows {
+		if i < len(row) && len(row[i]) > 0 {
+			kinds[valueKind(row[i])]++
+			total++
+		}
+	}
+
+	for kind, n := range kinds {
+		if len(kind) > 0 && float64(n) >= typeShare*float64(total) {
+			return kind
+		}
+	}
+
+	return ""
+}
+
+/*
+valueKind :: Field type of a value, number for numeric values
+*/
+func valueKind(value string) string {
+	switch {
+	case emailValue.MatchString(value):
+		return "email"
+	case net.ParseIP(value) != nil:
+		return "ip"
+	case hashValue.MatchString(value):
+		return "hash"
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return "number"
+	}
+
+	return ""
+}
+
+/*
+columnSpecs :: Columns value of detected columns. Named formats select
+every column when no type is found
+*/
+func columnSpecs(columns []*common.DetectedColumn, positional bool) string {
+	typed := false
+	for _, c := range columns {
+		typed = typed || len(c.Type) > 0
+	}
+	if !positional && !typed {
+		return ""
+	}
+
+	specs := []string{}
+	for _, c := range columns {
+		/* Names with spec separators can not be selected */
+		if strings.ContainsAny(c.Selector+c.Name, ",:") {
+			continue
+		}
+		spec := c.Selector
+		switch {
+		case len(c.Type) > 0:
+			spec += ":" + c.Name + ":" + c.Type
+		case c.Name != c.Selector:
+			spec += ":" + c.Name
+		}
+		specs = append(specs, spec)
+	}
+
+	return strings.Join(specs, ",")
+}
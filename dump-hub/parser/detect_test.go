@@ -0,0 +1,156 @@
+package parser
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/x0e1f/dump-hub/common"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name   string
+		sample string
+		kind   string
+		config common.ParserConfig
+	}{
+		{
+			name:   "combolist",
+			sample: "a@b.c:pw1\nd@e.f:pw2\ng@h.i:pw3\n",
+			kind:   "combolist",
+			config: common.ParserConfig{Format: FormatText, Pattern: "{:}{}", Columns: "0:email:email,1"},
+		},
+		{
+			name:   "header row",
+			sample: "email,password,ip\na@b.c,x,1.2.3.4\nd@e.f,y,5.6.7.8\n",
+			kind:   FormatCSV,
+			config: common.ParserConfig{
+				Format:  FormatText,
+				Pattern: "{,}{}",
+				Columns: "email:email:email,password,ip:ip:ip",
+				Header:  true,
+			},
+		},
+		{
+			name:   "repeated header names",
+			sample: "name,name,ip\nbob,smith,1.2.3.4\nann,lee,5.6.7.8\n",
+			kind:   FormatCSV,
+			config: common.ParserConfig{
+				Format:  FormatText,
+				Pattern: "{,}{}",
+				Columns: "name,name_2,ip:ip:ip",
+				Header:  true,
+			},
+		},
+		{
+			name:   "type named like a header",
+			sample: "ip,,email\n1.2.3.4,a@b.c,x@y.z\n5.6.7.8,d@e.f,w@v.u\n",
+			kind:   FormatCSV,
+			config: common.ParserConfig{
+				Format:  FormatText,
+				Pattern: "{,}{}",
+				Columns: "ip:ip:ip,1:email_1:email,email:email:email",
+				Header:  true,
+			},
+		},
+		{
+			name:   "quoted fields",
+			sample: "\"a@b.c\",\"x,y\"\n\"d@e.f\",\"z\"\n",
+			kind:   FormatCSV,
+			config: common.ParserConfig{Format: FormatCSV, Pattern: "{,}{}", Columns: "0:email:email,1", Quote: `"`},
+		},
+		{
+			name:   "comments and tabs",
+			sample: "# leak\na@b.c\tpw\tbob\nd@e.f\tpw\tann\n",
+			kind:   FormatText,
+			config: common.ParserConfig{Format: FormatText, Pattern: `{\t}{#}`, Columns: "0:email:email,1,2"},
+		},
+		{
+			name:   "repeated types",
+			sample: "a@b.c;d@e.f;pw\ng@h.i;j@k.l;pw\n",
+			kind:   FormatText,
+			config: common.ParserConfig{Format: FormatText, Pattern: "{;}{}", Columns: "0:email:email,1:email_1:email,2"},
+		},
+		{
+			name:   "brackets in text",
+			sample: "[x]:pw\n[y]:pw2\n",
+			kind:   "combolist",
+			config: common.ParserConfig{Format: FormatText, Pattern: "{:}{}", Columns: "0,1"},
+		},
+		{
+			name:   "json lines",
+			sample: "{\"email\":\"a@b.c\",\"user\":{\"name\":\"x\"}}\n{\"email\":\"d@e.f\"}\n",
+			kind:   FormatJSON,
+			config: common.ParserConfig{Format: FormatJSON, Columns: "email:email:email,user.name"},
+		},
+		{
+			name:   "json array without types",
+			sample: "[{\"a\":\"1\"},\n{\"a\":\"2\"}]\n",
+			kind:   FormatJSON,
+			config: common.ParserConfig{Format: FormatJSON},
+		},
+		{
+			name: "sql",
+			sample: "CREATE TABLE users (id int, email varchar(255));\n" +
+				"INSERT INTO users VALUES (1,'a@b.c'),(2,'d@e.f');\n",
+			kind:   FormatSQL,
+			config: common.ParserConfig{Format: FormatSQL, Table: "users", Columns: "id,email:email:email"},
+		},
+		{
+			name:   "copy",
+			sample: "COPY public.users (id, email) FROM stdin;\n1\ta@b.c\n2\td@e.f\n\\.\n",
+			kind:   FormatCopy,
+			config: common.ParserConfig{Format: FormatCopy, Table: "public.users", Columns: "id,email:email:email"},
+		},
+	}
+
+	for _, test := range tests {
+		detection, err := Detect([]byte(test.sample))
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if detection.Kind != test.kind {
+			t.Errorf("%s: kind: got %s, want %s", test.name, detection.Kind, test.kind)
+		}
+		if !reflect.DeepEqual(*detection.Config, test.config) || detection.Header != test.config.Header {
+			t.Errorf("%s: config\n got %+v\nwant %+v", test.name, *detection.Config, test.config)
+		}
+
+		/* Detected settings parse the sample */
+		if _, err := New(detection.Config); err != nil {
+			t.Errorf("%s: %s", test.name, err)
+		}
+	}
+}
+
+func TestDetectEmpty(t *testing.T) {
+	for _, sample := range []string{"", "\n \n"} {
+		if _, err := Detect([]byte(sample)); err == nil {
+			t.Errorf("%q: expected an error", sample)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package parser
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/x0e1f/dump-hub/common"
+)
+
+/*
+arraySeparator :: Separator of joined array values
+*/
+const arraySeparator = ", "
+
+/*
+parseJSON :: Stream JSON records from reader, both JSON Lines
+and a top-level array of objects are supported
+*/
+func (p *Parser) parseJSON(r io.Reader, filename string, checkSum string, handler func(*common.Entry), skip func(*Skipped)) error {
+	reader := bufio.NewReader(r)
+	lines := p.newLineReader(reader, skip)
+
+	/* Look for the first non blank character */
+	for {
+		c, err := reader.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if c == '\n' {
+			lines.line++
+		}
+		if c == ' ' || c == '\t' || c == '\r' || c == '\n' {
+			continue
+		}
+		reader.UnreadByte()
+		if c == '[' {
+			return p.parseJSONArray(reader, filename, checkSum, handler, skip)
+		}
+		break
+	}
+
+	/* JSON Lines, one record per line */
+	for {
+		line, err := lines.readLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(strings.TrimSpace(line)) < 1 {
+			lines.skipLine(line, ReasonEmpty)
+			continue
+		}
+
+		var record interface{}
+		decoder := json.NewDecoder(strings.NewReader(line))
+		decoder.UseNumber()
+		if decoder.Decode(&record) != nil {
+			lines.skipLine(line, ReasonInvalidJSON)
+			continue
+		}
+
+		entry := p.jsonEntry(filename, checkSum, record)
+		if entry == nil {
+			lines.skipLine(line, ReasonNoFields)
+			continue
+		}
+		handler(entry)
+	}
+}
+
+/*
+parseJSONArray :: Decode array elements one at a time
+*/
+func (p *Parser) parseJSONArray(r io.Reader, filename string, checkSum string, handler func(*common.Entry), skip func(*Skipped)) error {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+
+	/* Opening bracket */
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+
+	for i := 1; decoder.More(); i++ {
+		var record interface{}
+		err := decoder.Decode(&record)
+		if err != nil {
+			return err
+		}
+
+		entry := p.jsonEntry(filename, checkSum, record)
+		if entry == nil {
+			if skip != nil {
+				raw, _ := json.Marshal(record)
+				skip(&Skipped{Line: i, Raw: string(raw), Reason: ReasonNoFields})
+			}
+			continue
+		}
+		handler(entry)
+	}
+
+	return nil
+}
+
+/*
+jsonEntry :: Build entry document from a decoded JSON record.
+Selecting an object path keeps every key nested under it.
+*/
+func (p *Parser) jsonEntry(filename string, checkSum string, record interface{}) *common.Entry {
+	flat := map[string]string{}
+	flatten("", record, flat)
+
+	entry := newEntry(filename, checkSum)
+	for key, value := range flat {
+		if len(p.columns) < 1 {
+			setField(entry, nil, fieldName(key), value)
+			continue
+		}
+
+		for _, c := range p.columns {
+			switch {
+			case key == c.selector:
+				setField(entry, c, c.name, value)
+			case strings.HasPrefix(key, c.selector+"."):
+				name := fieldName(c.name + strings.TrimPrefix(key, c.selector))
+				setField(entry, c, name, value)
+			}
+		}
+	}
+	if len(entry.Fields) < 1 {
+		return nil
+	}
+
+	return entry
+}
+
+/*
+flatten :: Flatten nested objects into dotted keys, values of arrays
+are joined so arrays of any length add a single key
+*/
+func flatten(prefix string, value interface{}, flat map[string]string) {
+	join := func(key string) string {
+		if len(prefix) < 1 {
+			return key
+		}
+		return prefix + "." + key
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			flatten(join(key), child, flat)
+		}
+	case []interface{}:
+		for _, child := range v {
+			flatten(prefix, child, flat)
+		}
+	case nil:
+	default:
+		if len(prefix) < 1 {
+			prefix = "value"
+		}
+		var text string
+		switch v := v.(type) {
+		case string:
+			text = v
+		case json.Number:
+			text = v.String()
+		case bool:
+			text = strconv.FormatBool(v)
+		}
+		switch {
+		case len(text) < 1:
+		case len(flat[prefix]) > 0:
+			flat[prefix] += arraySeparator + text
+		default:
+			flat[prefix] = text
+		}
+	}
+}
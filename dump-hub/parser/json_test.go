@@ -0,0 +1,136 @@
+package parser
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"testing"
+
+	"github.com/x0e1f/dump-hub/common"
+)
+
+func TestParseJSON(t *testing.T) {
+	nested := `{"user":{"email":"a@b.c","tags":["x","y"]},"n":5,"ok":true,"none":null,"empty":""}` + "\n"
+	tests := []struct {
+		name    string
+		config  common.ParserConfig
+		input   string
+		entries []map[string]string
+		skipped []string
+	}{
+		{
+			name:    "json lines",
+			input:   "{\"email\":\"a@b.c\",\"password\":\"pw\"}\r\n{\"email\":\"d@e.f\"}",
+			entries: []map[string]string{{"email": "a@b.c", "password": "pw"}, {"email": "d@e.f"}},
+		},
+		{
+			name:  "nested values",
+			input: nested,
+			entries: []map[string]string{
+				{"user_email": "a@b.c", "user_tags": "x, y", "n": "5", "ok": "true"},
+			},
+		},
+		{
+			name:    "selected path",
+			config:  common.ParserConfig{Columns: "user.email:email:email,n"},
+			input:   nested,
+			entries: []map[string]string{{"email": "a@b.c", "n": "5"}},
+		},
+		{
+			name:   "selected object",
+			config: common.ParserConfig{Columns: "user:u"},
+			input:  nested,
+			entries: []map[string]string{
+				{"u_email": "a@b.c", "u_tags": "x, y"},
+			},
+		},
+		{
+			name:    "arrays of objects",
+			input:   `{"items":[{"a":1,"b":"x"},{"a":2},{"a":null,"b":""},{"b":["y",["z"]]}]}` + "\n",
+			entries: []map[string]string{{"items_a": "1, 2", "items_b": "x, y, z"}},
+		},
+		{
+			name:    "selected array",
+			config:  common.ParserConfig{Columns: "user.tags:tags"},
+			input:   nested,
+			entries: []map[string]string{{"tags": "x, y"}},
+		},
+		{
+			name:    "top-level scalar array",
+			input:   "{\"a\":\"1\"}\n[\"x\",\"y\"]\n",
+			entries: []map[string]string{{"a": "1"}, {"value": "x, y"}},
+		},
+		{
+			name:    "large numbers",
+			input:   `{"id":12345678901234567890,"f":1.50}` + "\n",
+			entries: []map[string]string{{"id": "12345678901234567890", "f": "1.50"}},
+		},
+		{
+			name:    "scalar values",
+			input:   "\"text\"\n42\n",
+			entries: []map[string]string{{"value": "text"}, {"value": "42"}},
+		},
+		{
+			name:    "invalid and empty lines",
+			config:  common.ParserConfig{Columns: "email"},
+			input:   "\n\n{\"email\":\"a\"}\n{bad\n  \n{\"email\":\"\"}\n",
+			entries: []map[string]string{{"email": "a"}},
+			skipped: []string{ReasonInvalidJSON, ReasonEmpty, ReasonNoFields},
+		},
+		{
+			name:    "array",
+			config:  common.ParserConfig{Columns: "a"},
+			input:   " \n[{\"a\":\"1\"},\n {\"b\":\"2\"}, {\"a\":[\"3\"]}]\n",
+			entries: []map[string]string{{"a": "1"}, {"a": "3"}},
+			skipped: []string{ReasonNoFields},
+		},
+	}
+
+	for _, test := range tests {
+		config := test.config
+		config.Format = FormatJSON
+		got, err := parseString(t, &config, test.input)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		checkResult(t, test.name, got, test.entries, test.skipped)
+	}
+}
+
+func TestParseJSONLines(t *testing.T) {
+	/* Blank lines before the first record are counted */
+	got, err := parseString(t, &common.ParserConfig{Format: FormatJSON}, "\n\n{\"a\":\"1\"}\nbad\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.lines) != 1 || got.lines[0] != 4 {
+		t.Errorf("skipped lines: got %v, want [4]", got.lines)
+	}
+
+	/* A broken array stops the parser */
+	_, err = parseString(t, &common.ParserConfig{Format: FormatJSON}, `[{"a":"1"},{"a"`)
+	if err == nil {
+		t.Error("expected an error for a truncated array")
+	}
+}
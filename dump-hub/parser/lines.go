@@ -0,0 +1,209 @@
+package parser
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"bufio"
+	"io"
+	"unicode/utf8"
+)
+
+/* Default maximum line length (bytes) */
+const defaultMaxLine = 1024 * 1024
+
+const (
+	// LongLineSkip :: Skip lines longer than the maximum length
+	LongLineSkip = "skip"
+	// LongLineTruncate :: Truncate lines longer than the maximum length
+	LongLineTruncate = "truncate"
+)
+
+const (
+	// ReasonEmpty :: Empty line
+	ReasonEmpty = "empty line"
+	// ReasonComment :: Line starting with the comment marker
+	ReasonComment = "comment"
+	// ReasonLongLine :: Line longer than the maximum length
+	ReasonLongLine = "line too long"
+	// ReasonNoMatch :: Line not matching the line regex
+	ReasonNoMatch = "no regex match"
+	// ReasonInvalidJSON :: Line is not a JSON value
+	ReasonInvalidJSON = "invalid json"
+	// ReasonNoFields :: None of the selected columns found
+	ReasonNoFields = "no selected fields"
+	// ReasonHeader :: Header row naming the columns
+	ReasonHeader = "header"
+	// ReasonUnterminated :: Quoted field not closed before the end of
+	// the file or the maximum record length
+	ReasonUnterminated = "unterminated quoted field"
+)
+
+/*
+Skipped :: Line skipped by the parser, Line is the record number
+for formats without lines (JSON arrays and SQL rows)
+*/
+type Skipped struct {
+	Line   int
+	Raw    string
+	Reason string
+}
+
+/*
+Rejected :: Check if the line was rejected
+*/
+func (s *Skipped) Rejected() bool {
+	return IsRejection(s.Reason)
+}
+
+/*
+IsRejection :: Check if reason is a rejection, empty, comment and
+header lines are skipped on purpose
+*/
+func IsRejection(reason string) bool {
+	return reason != ReasonEmpty && reason != ReasonComment && reason != ReasonHeader
+}
+
+/*
+lineReader :: Line reader with a maximum line length, unlike
+bufio.Scanner long lines do not stop the reader
+*/
+type lineReader struct {
+	reader    *bufio.Reader
+	maxLength int
+	truncate  bool
+	line      int
+	skip      func(*Skipped)
+}
+
+/*
+newLineReader :: Create line reader with parser line settings
+*/
+func (p *Parser) newLineReader(reader *bufio.Reader, skip func(*Skipped)) *lineReader {
+	if skip == nil {
+		skip = func(*Skipped) {}
+	}
+
+	return &lineReader{
+		reader:    reader,
+		maxLength: p.maxLine,
+		truncate:  p.truncate,
+		skip:      skip,
+	}
+}
+
+/*
+readLine :: Read next line without line terminator. Long lines are
+truncated, or reported as skipped and the following line is returned
+*/
+func (l *lineReader) readLine() (string, error) {
+	for {
+		line, long, err := l.read()
+		if err != nil {
+			return "", err
+		}
+		l.line++
+
+		if !long || l.truncate {
+			return line, nil
+		}
+		l.skipLine(line, ReasonLongLine)
+	}
+}
+
+/*
+skipLine :: Report the last line read as skipped
+*/
+func (l *lineReader) skipLine(raw string, reason string) {
+	l.skip(&Skipped{
+		Line:   l.line,
+		Raw:    raw,
+		Reason: reason,
+	})
+}
+
+/*
+read :: Read a line keeping at most maxLength bytes of it, the
+remaining bytes are discarded
+*/
+func (l *lineReader) read() (string, bool, error) {
+	line := []byte{}
+	long := false
+	read := false
+
+	for {
+		chunk, err := l.reader.ReadSlice('\n')
+		if err != nil && err != bufio.ErrBufferFull && err != io.EOF {
+			return "", false, err
+		}
+		if err == io.EOF && len(chunk) < 1 && !read {
+			return "", false, io.EOF
+		}
+		read = true
+
+		/* Line terminator is not part of the line */
+		end := err == nil
+		if end {
+			chunk = chunk[:len(chunk)-1]
+			if len(chunk) > 0 && chunk[len(chunk)-1] == '\r' {
+				chunk = chunk[:len(chunk)-1]
+			}
+		}
+
+		free := l.maxLength - len(line)
+		if len(chunk) > free {
+			chunk = chunk[:free]
+			long = true
+		}
+		line = append(line, chunk...)
+
+		if end || err == io.EOF {
+			break
+		}
+	}
+
+	if len(line) > 0 && line[len(line)-1] == '\r' {
+		line = line[:len(line)-1]
+	}
+	if long {
+		line = trimRune(line)
+	}
+
+	return string(line), long, nil
+}
+
+/*
+trimRune :: Remove a rune truncated at the end of a line
+*/
+func trimRune(line []byte) []byte {
+	for i := len(line) - 1; i >= 0 && i >= len(line)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(line[i]) {
+			if !utf8.FullRune(line[i:]) {
+				return line[:i]
+			}
+			break
+		}
+	}
+
+	return line
+}
@@ -0,0 +1,129 @@
+package parser
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/x0e1f/dump-hub/common"
+)
+
+func TestParseLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  common.ParserConfig
+		input   string
+		entries []map[string]string
+		skipped []string
+		lines   []int
+	}{
+		{
+			name:    "line terminators",
+			input:   "a:b\r\nc:d\ne:f",
+			entries: []map[string]string{{"0": "a", "1": "b"}, {"0": "c", "1": "d"}, {"0": "e", "1": "f"}},
+		},
+		{
+			name:    "skipped line numbers",
+			input:   "#\na:b\n\n#c:d\n",
+			entries: []map[string]string{{"0": "a", "1": "b"}},
+			skipped: []string{ReasonComment, ReasonEmpty, ReasonComment},
+			lines:   []int{1, 3, 4},
+		},
+		{
+			name:    "long line skipped",
+			config:  common.ParserConfig{MaxLine: 8},
+			input:   "a:b\ncccccccccc:d\ne:f",
+			entries: []map[string]string{{"0": "a", "1": "b"}, {"0": "e", "1": "f"}},
+			skipped: []string{ReasonLongLine},
+			lines:   []int{2},
+		},
+		{
+			name:    "line at max length",
+			config:  common.ParserConfig{MaxLine: 5},
+			input:   "abc:d\r\n",
+			entries: []map[string]string{{"0": "abc", "1": "d"}},
+		},
+		{
+			name:    "long line truncated",
+			config:  common.ParserConfig{MaxLine: 5, LongLines: LongLineTruncate},
+			input:   "abc:defgh\nx:y\n",
+			entries: []map[string]string{{"0": "abc", "1": "d"}, {"0": "x", "1": "y"}},
+		},
+		{
+			name:    "truncated rune",
+			config:  common.ParserConfig{MaxLine: 5, LongLines: LongLineTruncate},
+			input:   "ab:cé\n",
+			entries: []map[string]string{{"0": "ab", "1": "c"}},
+		},
+	}
+
+	for _, test := range tests {
+		config := test.config
+		config.Format = FormatText
+		config.Pattern = "{:}{#}"
+		config.Columns = "0,1"
+		got, err := parseString(t, &config, test.input)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		checkResult(t, test.name, got, test.entries, test.skipped)
+		if test.lines != nil && !reflect.DeepEqual(got.lines, test.lines) {
+			t.Errorf("%s: lines\n got %v\nwant %v", test.name, got.lines, test.lines)
+		}
+	}
+}
+
+func TestLineConfig(t *testing.T) {
+	invalid := []common.ParserConfig{
+		{MaxLine: -1},
+		{LongLines: "wrap"},
+	}
+	for _, config := range invalid {
+		config.Pattern = "{:}{}"
+		config.Columns = "0"
+		if _, err := New(&config); err == nil {
+			t.Errorf("%+v: expected an error", config)
+		}
+	}
+}
+
+func TestIsRejection(t *testing.T) {
+	reasons := map[string]bool{
+		ReasonEmpty:        false,
+		ReasonComment:      false,
+		ReasonHeader:       false,
+		ReasonLongLine:     true,
+		ReasonNoMatch:      true,
+		ReasonInvalidJSON:  true,
+		ReasonNoFields:     true,
+		ReasonUnterminated: true,
+	}
+	for reason, rejected := range reasons {
+		if IsRejection(reason) != rejected {
+			t.Errorf("%s: got %t, want %t", reason, !rejected, rejected)
+		}
+	}
+}
@@ -24,99 +24,296 @@ OTHER DEALINGS IN THE SOFTWARE.
 */
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
-	"strconv"
+	"io"
+	"regexp"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/x0e1f/dump-hub/common"
 )
 
+const (
+	// FormatText :: Lines split by separator
+	FormatText = "text"
+	// FormatCSV :: RFC 4180 records with quoted fields
+	FormatCSV = "csv"
+	// FormatRegex :: Lines matched by a regex with named groups
+	FormatRegex = "regex"
+	// FormatJSON :: JSON Lines or array of JSON objects
+	FormatJSON = "json"
+	// FormatSQL :: INSERT statements of a SQL dump
+	FormatSQL = "sql"
+	// FormatCopy :: PostgreSQL COPY ... FROM stdin blocks
+	FormatCopy = "copy"
+)
+
 /*
 Parser :: Parser object
 */
 type Parser struct {
+	format      string
 	separator   string
+	regex       *regexp.Regexp
 	commentChar string
-	columns     []int
+	quote       rune
+	escape      rune
+	columns     []*column
+	lineRegex   *regexp.Regexp
+	table       string
+	maxLine     int
+	truncate    bool
+	header      bool
 }
 
+/*
+maxTableRows :: Sample rows returned for every table
+*/
+const maxTableRows = 20
+
 /*
 New :: Create new parser object
 */
-func New(pattern string, columnsRaw string) (*Parser, error) {
-	p := &Parser{}
-
-	_, err := fmt.Sscanf(
-		pattern,
-		"{%1s}{%1s}",
-		&p.separator,
-		&p.commentChar,
-	)
-	if err != nil {
-		return nil, err
+func New(config *common.ParserConfig) (*Parser, error) {
+	p := &Parser{
+		format:  config.Format,
+		maxLine: config.MaxLine,
+	}
+	if len(p.format) < 1 {
+		p.format = FormatText
+	}
+
+	/* Long lines settings */
+	if p.maxLine < 0 {
+		return nil, fmt.Errorf("invalid max line length: %d", p.maxLine)
+	}
+	if p.maxLine == 0 {
+		p.maxLine = defaultMaxLine
+	}
+	switch config.LongLines {
+	case "", LongLineSkip:
+	case LongLineTruncate:
+		p.truncate = true
+	default:
+		return nil, fmt.Errorf("unknown long lines policy: %s", config.LongLines)
 	}
 
-	columnsRaw = strings.Replace(columnsRaw, " ", "", -1)
-	columns := strings.Split(columnsRaw, ",")
+	switch p.format {
+	case FormatText, FormatCSV:
+		err := p.setPattern(config.Pattern)
+		if err != nil {
+			return nil, err
+		}
 
-	for _, column := range columns {
-		columnValue, err := strconv.Atoi(column)
+		/* Header names can select columns */
+		p.header = config.Header
+		err = p.setColumns(config.Columns, !p.header)
+		if err != nil {
+			return nil, err
+		}
+	case FormatRegex:
+		if len(config.Pattern) > 0 {
+			err := p.setPattern(config.Pattern)
+			if err != nil {
+				return nil, err
+			}
+		}
+		err := p.setRegex(config.Regex, config.Columns)
+		if err != nil {
+			return nil, err
+		}
+	case FormatJSON:
+		err := p.setColumns(config.Columns, false)
 		if err != nil {
 			return nil, err
 		}
-		p.columns = append(p.columns, columnValue)
+	case FormatSQL, FormatCopy:
+		p.table = strings.TrimSpace(config.Table)
+		err := p.setColumns(config.Columns, false)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown format: %s", p.format)
+	}
+	if config.Header && !p.header {
+		return nil, fmt.Errorf("%s format has no header row", p.format)
+	}
+
+	if p.format == FormatCSV {
+		if p.regex != nil {
+			return nil, errors.New("csv format requires a literal separator")
+		}
+
+		var err error
+		p.quote, err = parseRune(config.Quote, '"')
+		if err != nil {
+			return nil, fmt.Errorf("invalid quote character: %s", err)
+		}
+		p.escape, err = parseRune(config.Escape, p.quote)
+		if err != nil {
+			return nil, fmt.Errorf("invalid escape character: %s", err)
+		}
 	}
 
 	return p, nil
 }
 
+/*
+setPattern :: Set separator and comment marker from pattern
+*/
+func (p *Parser) setPattern(value string) error {
+	if len(value) < 1 {
+		return errors.New("pattern value not found")
+	}
+
+	pattern, err := parsePattern(value)
+	if err != nil {
+		return err
+	}
+	p.separator = pattern.separator
+	p.regex = pattern.regex
+	p.commentChar = pattern.commentChar
+
+	return nil
+}
+
+/*
+Parse :: Parse all entries from reader, skipped lines are
+reported to skip (may be nil)
+*/
+func (p *Parser) Parse(r io.Reader, filename string, checkSum string, handler func(*common.Entry), skip func(*Skipped)) error {
+	switch p.format {
+	case FormatCSV:
+		return p.parseCSV(r, filename, checkSum, handler, skip)
+	case FormatJSON:
+		return p.parseJSON(r, filename, checkSum, handler, skip)
+	case FormatSQL:
+		return p.parseSQL(r, filename, checkSum, handler, skip)
+	case FormatCopy:
+		return p.parseCopy(r, filename, checkSum, handler, skip)
+	}
+
+	lines := p.newLineReader(bufio.NewReader(r), skip)
+	parser := p
+	for {
+		line, err := lines.readLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		/* First line not skipped names the columns */
+		if parser.header {
+			if reason := p.skipReason(line); len(reason) > 0 {
+				lines.skipLine(line, reason)
+				continue
+			}
+			parser, err = p.withHeader(p.split(line))
+			if err != nil {
+				return err
+			}
+			lines.skipLine(line, ReasonHeader)
+			continue
+		}
+
+		entry, reason := parser.parseLine(filename, checkSum, line)
+		if entry == nil {
+			lines.skipLine(line, reason)
+			continue
+		}
+		handler(entry)
+	}
+}
+
+/*
+Tables :: List tables (with sample rows) found in a dump
+*/
+func (p *Parser) Tables(r io.Reader) ([]*common.Table, error) {
+	switch p.format {
+	case FormatSQL:
+		return sqlTables(r, maxTableRows)
+	case FormatCopy:
+		return p.copyTables(r, maxTableRows)
+	}
+
+	return nil, fmt.Errorf("%s format has no tables", p.format)
+}
+
 /*
 ParseEntry :: Parse dump entry from file
 */
 func (p *Parser) ParseEntry(filename string, checkSum string, entry string) *common.Entry {
-	obj := &common.Entry{}
-	data := []string{}
+	parsed, _ := p.parseLine(filename, checkSum, entry)
+	return parsed
+}
+
+/*
+parseLine :: Parse a single line, returns the reason why the line
+was skipped if no entry is found
+*/
+func (p *Parser) parseLine(filename string, checkSum string, entry string) (*common.Entry, string) {
+	if reason := p.skipReason(entry); len(reason) > 0 {
+		return nil, reason
+	}
+
+	if p.format == FormatRegex {
+		return p.matchEntry(filename, checkSum, entry)
+	}
+
+	parsed := p.positionalEntry(filename, checkSum, p.split(entry))
+	if parsed == nil {
+		return nil, ReasonNoFields
+	}
 
+	return parsed, ""
+}
+
+/*
+skipReason :: Reason why a line is skipped before parsing it (empty
+and comment lines), empty if the line is parsed
+*/
+func (p *Parser) skipReason(entry string) string {
 	/* If line empty */
 	if len(entry) < 1 {
-		return nil
+		return ReasonEmpty
 	}
 
 	/* Remove whitespaces from line */
 	line := strings.Replace(entry, " ", "", -1)
-	if string(line[0]) == p.commentChar {
-		return nil
+	if len(p.commentChar) > 0 && strings.HasPrefix(line, p.commentChar) {
+		return ReasonComment
 	}
 
-	/* Split line with separator */
-	matches := strings.Split(entry, p.separator)
-	if len(matches) < 1 {
-		return nil
+	return ""
+}
+
+/*
+split :: Split line with separator
+*/
+func (p *Parser) split(entry string) []string {
+	if p.regex != nil {
+		return p.regex.Split(entry, -1)
 	}
 
-	/* Iterate trough all fields */
-	for i, match := range matches {
-		if len(match) < 1 {
-			continue
-		}
+	return strings.Split(entry, p.separator)
+}
 
-		/* Add value only if index in column */
-		for _, column := range p.columns {
-			if i == column {
-				data = append(data, match)
-			}
-		}
+/*
+parseRune :: Parse a single character option
+*/
+func parseRune(value string, fallback rune) (rune, error) {
+	if len(value) < 1 {
+		return fallback, nil
 	}
-
-	/* If object still empty */
-	if obj == nil {
-		return nil
+	if utf8.RuneCountInString(value) != 1 {
+		return 0, fmt.Errorf("expected a single character, got %q", value)
 	}
 
-	/* Set origin fields */
-	obj.Origin = filename
-	obj.OriginID = checkSum
-	obj.Data = data
-
-	return obj
+	r, _ := utf8.DecodeRuneInString(value)
+	return r, nil
 }
@@ -0,0 +1,80 @@
+package parser
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/x0e1f/dump-hub/common"
+)
+
+/*
+parseResult :: Fields of the parsed entries and reasons of the
+skipped lines
+*/
+type parseResult struct {
+	entries []map[string]string
+	skipped []string
+	lines   []int
+}
+
+func parseString(t *testing.T, config *common.ParserConfig, input string) (*parseResult, error) {
+	t.Helper()
+	p, err := New(config)
+	if err != nil {
+		t.Fatalf("parser creation: %s", err)
+	}
+
+	result := &parseResult{
+		entries: []map[string]string{},
+		skipped: []string{},
+		lines:   []int{},
+	}
+	err = p.Parse(strings.NewReader(input), "file", "checksum", func(entry *common.Entry) {
+		result.entries = append(result.entries, entry.Fields)
+	}, func(line *Skipped) {
+		result.skipped = append(result.skipped, line.Reason)
+		result.lines = append(result.lines, line.Line)
+	})
+
+	return result, err
+}
+
+func checkResult(t *testing.T, name string, got *parseResult, entries []map[string]string, skipped []string) {
+	t.Helper()
+	if entries == nil {
+		entries = []map[string]string{}
+	}
+	if skipped == nil {
+		skipped = []string{}
+	}
+	if !reflect.DeepEqual(got.entries, entries) {
+		t.Errorf("%s: entries\n got %v\nwant %v", name, got.entries, entries)
+	}
+	if !reflect.DeepEqual(got.skipped, skipped) {
+		t.Errorf("%s: skipped\n got %v\nwant %v", name, got.skipped, skipped)
+	}
+}
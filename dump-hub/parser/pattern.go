@@ -0,0 +1,130 @@
+package parser
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+/*
+pattern :: Parsed parser pattern
+*/
+type pattern struct {
+	separator   string
+	regex       *regexp.Regexp
+	commentChar string
+}
+
+/*
+parsePattern :: Parse pattern string.
+
+Patterns are written as {separator}{comment}, the separator can be
+any string and an r prefix (r{regex}{comment}) turns it into a
+regular expression. Inside braces \} and \{ escape braces, \\ is a
+backslash and \t a tab. The comment marker may be empty.
+*/
+func parsePattern(value string) (*pattern, error) {
+	p := &pattern{}
+
+	isRegex := strings.HasPrefix(value, "r{")
+	if isRegex {
+		value = value[1:]
+	}
+
+	separator, rest, err := readGroup(value, isRegex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid separator: %s", err)
+	}
+	if len(separator) < 1 {
+		return nil, errors.New("invalid separator: separator is empty")
+	}
+
+	p.commentChar, rest, err = readGroup(rest, false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid comment marker: %s", err)
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("unexpected trailing characters %q", rest)
+	}
+
+	if !isRegex {
+		p.separator = separator
+		return p, nil
+	}
+
+	p.regex, err = regexp.Compile(separator)
+	if err != nil {
+		return nil, fmt.Errorf("invalid separator regex: %s", err)
+	}
+	if p.regex.MatchString("") {
+		return nil, errors.New("invalid separator regex: regex matches empty string")
+	}
+
+	return p, nil
+}
+
+/*
+readGroup :: Read a {...} group, returns content and remaining string.
+Raw groups keep escape sequences untouched (used for regex).
+*/
+func readGroup(value string, raw bool) (string, string, error) {
+	if !strings.HasPrefix(value, "{") {
+		return "", "", errors.New("pattern must look like {separator}{comment}")
+	}
+
+	content := strings.Builder{}
+	depth := 0
+	for i := 1; i < len(value); i++ {
+		c := value[i]
+
+		switch {
+		case c == '\\' && i+1 < len(value):
+			i++
+			if raw {
+				content.WriteByte(c)
+				content.WriteByte(value[i])
+				continue
+			}
+			switch value[i] {
+			case 't':
+				content.WriteByte('\t')
+			default:
+				content.WriteByte(value[i])
+			}
+			continue
+		case c == '{' && raw:
+			depth++
+		case c == '}' && depth > 0:
+			depth--
+		case c == '}':
+			return content.String(), value[i+1:], nil
+		}
+		content.WriteByte(c)
+	}
+
+	return "", "", errors.New("missing closing brace")
+}
@@ -0,0 +1,140 @@
+package parser
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"testing"
+
+	"github.com/x0e1f/dump-hub/common"
+)
+
+func TestParseText(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  common.ParserConfig
+		input   string
+		entries []map[string]string
+		skipped []string
+	}{
+		{
+			name:    "separator",
+			config:  common.ParserConfig{Pattern: "{:}{}", Columns: "0,1"},
+			input:   "a@b.c:pw\nd@e.f:p:w\n",
+			entries: []map[string]string{{"0": "a@b.c", "1": "pw"}, {"0": "d@e.f", "1": "p"}},
+		},
+		{
+			name:    "multi character separator",
+			config:  common.ParserConfig{Pattern: "{::}{}", Columns: "0,1"},
+			input:   "a:b::c\n",
+			entries: []map[string]string{{"0": "a:b", "1": "c"}},
+		},
+		{
+			name:    "tab separator",
+			config:  common.ParserConfig{Pattern: `{\t}{}`, Columns: "0,1"},
+			input:   "a b\tc\n",
+			entries: []map[string]string{{"0": "a b", "1": "c"}},
+		},
+		{
+			name:    "escaped braces",
+			config:  common.ParserConfig{Pattern: `{\}\{}{\\}`, Columns: "0,1"},
+			input:   "\\ comment\na}{b\n",
+			entries: []map[string]string{{"0": "a", "1": "b"}},
+			skipped: []string{ReasonComment},
+		},
+		{
+			name:    "regex separator",
+			config:  common.ParserConfig{Pattern: `r{[,;]+|\s{2,}}{}`, Columns: "0,1,2"},
+			input:   "a,;b  c\n",
+			entries: []map[string]string{{"0": "a", "1": "b", "2": "c"}},
+		},
+		{
+			name:    "comment marker",
+			config:  common.ParserConfig{Pattern: "{:}{//}", Columns: "0"},
+			input:   "// c\n  / / d\n\na:b\n/x:y\n",
+			entries: []map[string]string{{"0": "a"}, {"0": "/x"}},
+			skipped: []string{ReasonComment, ReasonComment, ReasonEmpty},
+		},
+		{
+			name:    "named and typed columns",
+			config:  common.ParserConfig{Pattern: "{:}{}", Columns: "1:password, 0:email:Email"},
+			input:   "a@b.c:pw\n",
+			entries: []map[string]string{{"email": "a@b.c", "password": "pw"}},
+		},
+		{
+			name:    "missing columns",
+			config:  common.ParserConfig{Pattern: "{:}{}", Columns: "0,2"},
+			input:   "a\n:b\n:b:c\n",
+			entries: []map[string]string{{"0": "a"}, {"2": "c"}},
+			skipped: []string{ReasonNoFields},
+		},
+	}
+
+	for _, test := range tests {
+		config := test.config
+		config.Format = FormatText
+		got, err := parseString(t, &config, test.input)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		checkResult(t, test.name, got, test.entries, test.skipped)
+	}
+}
+
+func TestParsePattern(t *testing.T) {
+	tests := []struct {
+		value     string
+		separator string
+		regex     string
+		comment   string
+	}{
+		{value: "{:}{#}", separator: ":", comment: "#"},
+		{value: "{:}{}", separator: ":"},
+		{value: `{\t}{--}`, separator: "\t", comment: "--"},
+		{value: `{\{\}\\}{}`, separator: `{}\`},
+		{value: `r{\s+}{#}`, regex: `\s+`, comment: "#"},
+		{value: `r{\{x{2}}{}`, regex: `\{x{2}`},
+	}
+	for _, test := range tests {
+		p, err := parsePattern(test.value)
+		if err != nil {
+			t.Errorf("%s: %s", test.value, err)
+			continue
+		}
+		regex := ""
+		if p.regex != nil {
+			regex = p.regex.String()
+		}
+		if p.separator != test.separator || regex != test.regex || p.commentChar != test.comment {
+			t.Errorf("%s: got %q %q %q", test.value, p.separator, regex, p.commentChar)
+		}
+	}
+
+	invalid := []string{"", ":", "{}{}", "{:}", "{:", "{:}{#", "{:}{#}x", "r{(}{}", "r{a*}{}"}
+	for _, value := range invalid {
+		if _, err := parsePattern(value); err == nil {
+			t.Errorf("%q: expected an error", value)
+		}
+	}
+}
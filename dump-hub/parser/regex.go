@@ -0,0 +1,97 @@
+package parser
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/x0e1f/dump-hub/common"
+)
+
+/*
+setRegex :: Set line regex, named groups become entry fields.
+Columns optionally restrict (and rename) the indexed groups.
+*/
+func (p *Parser) setRegex(value string, columns string) error {
+	if len(value) < 1 {
+		return errors.New("regex value not found")
+	}
+
+	lineRegex, err := regexp.Compile(value)
+	if err != nil {
+		return fmt.Errorf("invalid regex: %s", err)
+	}
+
+	groups := map[string]bool{}
+	for _, name := range lineRegex.SubexpNames() {
+		if len(name) > 0 {
+			groups[name] = true
+		}
+	}
+	if len(groups) < 1 {
+		return errors.New("invalid regex: no named capture groups (?P<name>...)")
+	}
+
+	err = p.setColumns(columns, false)
+	if err != nil {
+		return err
+	}
+	for _, c := range p.columns {
+		if !groups[c.selector] {
+			return fmt.Errorf("invalid column: no capture group named %q", c.selector)
+		}
+	}
+	p.lineRegex = lineRegex
+
+	return nil
+}
+
+/*
+matchEntry :: Build entry document from regex named groups, returns
+the reason why the line was skipped if no entry is found
+*/
+func (p *Parser) matchEntry(filename string, checkSum string, entry string) (*common.Entry, string) {
+	matches := p.lineRegex.FindStringSubmatch(entry)
+	if matches == nil {
+		return nil, ReasonNoMatch
+	}
+
+	/* Unnamed groups are never indexed */
+	names := p.lineRegex.SubexpNames()
+	values := make([]*string, len(matches))
+	for i := 1; i < len(matches); i++ {
+		if len(names[i]) > 0 {
+			values[i] = &matches[i]
+		}
+	}
+
+	parsed := p.namedEntry(filename, checkSum, names, values)
+	if parsed == nil {
+		return nil, ReasonNoFields
+	}
+
+	return parsed, ""
+}
@@ -0,0 +1,101 @@
+package parser
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"testing"
+
+	"github.com/x0e1f/dump-hub/common"
+)
+
+func TestParseRegex(t *testing.T) {
+	combo := `^(?P<email>[^:]+):(?P<password>.*)$`
+	tests := []struct {
+		name    string
+		config  common.ParserConfig
+		input   string
+		entries []map[string]string
+		skipped []string
+	}{
+		{
+			name:    "named groups",
+			config:  common.ParserConfig{Regex: combo},
+			input:   "a@b.c:pw\nnope\n",
+			entries: []map[string]string{{"email": "a@b.c", "password": "pw"}},
+			skipped: []string{ReasonNoMatch},
+		},
+		{
+			name:    "unnamed groups",
+			config:  common.ParserConfig{Regex: `^(\d+) (?P<user>\w+)$`},
+			input:   "12 bob\n",
+			entries: []map[string]string{{"user": "bob"}},
+		},
+		{
+			name:    "selected groups",
+			config:  common.ParserConfig{Regex: combo, Columns: "email:mail:email"},
+			input:   "a@b.c:pw\n",
+			entries: []map[string]string{{"mail": "a@b.c"}},
+		},
+		{
+			name:    "empty groups",
+			config:  common.ParserConfig{Regex: combo, Columns: "password"},
+			input:   "a@b.c:\nd@e.f:pw\n",
+			entries: []map[string]string{{"password": "pw"}},
+			skipped: []string{ReasonNoFields},
+		},
+		{
+			name:    "comment marker",
+			config:  common.ParserConfig{Regex: combo, Pattern: "{:}{#}"},
+			input:   "# a:b\n\nc:d\n",
+			entries: []map[string]string{{"email": "c", "password": "d"}},
+			skipped: []string{ReasonComment, ReasonEmpty},
+		},
+	}
+
+	for _, test := range tests {
+		config := test.config
+		config.Format = FormatRegex
+		got, err := parseString(t, &config, test.input)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		checkResult(t, test.name, got, test.entries, test.skipped)
+	}
+}
+
+func TestRegexConfig(t *testing.T) {
+	invalid := []common.ParserConfig{
+		{},
+		{Regex: `(`},
+		{Regex: `^(\w+):(\w+)$`},
+		{Regex: `^(?P<user>\w+)$`, Columns: "email"},
+	}
+	for _, config := range invalid {
+		config.Format = FormatRegex
+		if _, err := New(&config); err == nil {
+			t.Errorf("%+v: expected an error", config)
+		}
+	}
+}
@@ -0,0 +1,833 @@
+package parser
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/x0e1f/dump-hub/common"
+)
+
+const (
+	tokenEOF = iota
+	tokenWord
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenSymbol
+)
+
+/*
+sqlToken :: SQL lexer token
+*/
+type sqlToken struct {
+	kind int
+	text string
+}
+
+/*
+sqlRow :: Row of an INSERT statement, nil values are NULL
+*/
+type sqlRow struct {
+	table   string
+	columns []string
+	values  []*string
+}
+
+/*
+sqlScanner :: Streaming SQL dump reader, emits one row at a time
+*/
+type sqlScanner struct {
+	reader    *bufio.Reader
+	tables    map[string][]string
+	order     []string
+	backslash bool
+}
+
+/*
+constraintWords :: CREATE TABLE items that are not column definitions
+*/
+var constraintWords = map[string]bool{
+	"PRIMARY":    true,
+	"KEY":        true,
+	"INDEX":      true,
+	"UNIQUE":     true,
+	"CONSTRAINT": true,
+	"FOREIGN":    true,
+	"FULLTEXT":   true,
+	"SPATIAL":    true,
+	"CHECK":      true,
+	"EXCLUDE":    true,
+	"LIKE":       true,
+}
+
+/*
+newSQLScanner :: Create SQL scanner. Backslash escapes (MySQL) are
+enabled until the dump turns standard_conforming_strings on (PostgreSQL).
+*/
+func newSQLScanner(r io.Reader) *sqlScanner {
+	return &sqlScanner{
+		reader:    bufio.NewReader(r),
+		tables:    map[string][]string{},
+		backslash: true,
+	}
+}
+
+/*
+parseSQL :: Parse rows of the selected table from INSERT statements
+*/
+func (p *Parser) parseSQL(r io.Reader, filename string, checkSum string, handler func(*common.Entry), skip func(*Skipped)) error {
+	table := p.table
+	rows := 0
+
+	return newSQLScanner(r).scan(func(row *sqlRow) {
+		/* Without a selected table use the first one found */
+		if len(table) < 1 {
+			table = row.table
+		}
+		if !tableMatch(row.table, table) {
+			return
+		}
+		rows++
+
+		entry := p.namedEntry(filename, checkSum, row.columns, row.values)
+		if entry == nil {
+			if skip != nil {
+				raw := strings.Join(rowValues(row.values), ",")
+				skip(&Skipped{Line: rows, Raw: raw, Reason: ReasonNoFields})
+			}
+			return
+		}
+		handler(entry)
+	})
+}
+
+/*
+scan :: Scan all statements, handler is called for every inserted row
+*/
+func (s *sqlScanner) scan(handler func(*sqlRow)) error {
+	for {
+		tok, err := s.next()
+		if err != nil {
+			return err
+		}
+		if tok.kind == tokenEOF {
+			return nil
+		}
+		if tok.kind != tokenWord {
+			continue
+		}
+
+		switch strings.ToUpper(tok.text) {
+		case "CREATE":
+			err = s.parseCreate()
+		case "INSERT", "REPLACE":
+			err = s.parseInsert(handler)
+		case "SET":
+			err = s.parseSet()
+		case "COPY":
+			err = s.skipCopy()
+		default:
+			err = s.skipStatement()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+/*
+parseCreate :: Read column names from CREATE TABLE statement
+*/
+func (s *sqlScanner) parseCreate() error {
+	tok, err := s.nextWordSkipping("TEMPORARY", "UNLOGGED")
+	if err != nil {
+		return err
+	}
+	if !isWord(tok, "TABLE") {
+		return s.skipStatementFrom(tok)
+	}
+
+	tok, err = s.nextWordSkipping("IF", "NOT", "EXISTS")
+	if err != nil {
+		return err
+	}
+	name, tok, err := s.readName(tok)
+	if err != nil {
+		return err
+	}
+	if !isSymbol(tok, "(") {
+		return s.skipStatementFrom(tok)
+	}
+
+	columns := []string{}
+	depth := 1
+	itemStart := true
+	for depth > 0 {
+		tok, err = s.next()
+		if err != nil {
+			return err
+		}
+		switch {
+		case tok.kind == tokenEOF:
+			return nil
+		case isSymbol(tok, "("):
+			depth++
+		case isSymbol(tok, ")"):
+			depth--
+		case isSymbol(tok, ",") && depth == 1:
+			itemStart = true
+			continue
+		case itemStart && (tok.kind == tokenIdent || tok.kind == tokenWord):
+			if tok.kind == tokenIdent || !constraintWords[strings.ToUpper(tok.text)] {
+				columns = append(columns, tok.text)
+			}
+		}
+		itemStart = false
+	}
+	if _, ok := s.tables[name]; !ok {
+		s.order = append(s.order, name)
+	}
+	s.tables[name] = columns
+
+	return s.skipStatement()
+}
+
+/*
+parseInsert :: Parse INSERT INTO name [(columns)] VALUES (...),(...)
+*/
+func (s *sqlScanner) parseInsert(handler func(*sqlRow)) error {
+	tok, err := s.nextWordSkipping("LOW_PRIORITY", "DELAYED", "HIGH_PRIORITY", "IGNORE", "INTO")
+	if err != nil {
+		return err
+	}
+	name, tok, err := s.readName(tok)
+	if err != nil {
+		return err
+	}
+
+	/* Explicit column list */
+	columns := s.tables[name]
+	if isSymbol(tok, "(") {
+		columns = []string{}
+		for {
+			tok, err = s.next()
+			if err != nil {
+				return err
+			}
+			if tok.kind == tokenEOF || isSymbol(tok, ")") {
+				break
+			}
+			if !isSymbol(tok, ",") {
+				columns = append(columns, tok.text)
+			}
+		}
+		tok, err = s.next()
+		if err != nil {
+			return err
+		}
+	}
+	if !isWord(tok, "VALUES") && !isWord(tok, "VALUE") {
+		return s.skipStatementFrom(tok)
+	}
+
+	/* Row tuples */
+	for {
+		tok, err = s.next()
+		if err != nil {
+			return err
+		}
+		if isSymbol(tok, ",") {
+			continue
+		}
+		if !isSymbol(tok, "(") {
+			return s.skipStatementFrom(tok)
+		}
+
+		values, err := s.readTuple()
+		if err != nil {
+			return err
+		}
+		handler(&sqlRow{
+			table:   name,
+			columns: columns,
+			values:  values,
+		})
+	}
+}
+
+/*
+readTuple :: Read a (value, ...) tuple, opening bracket already consumed
+*/
+func (s *sqlScanner) readTuple() ([]*string, error) {
+	values := []*string{}
+	value := strings.Builder{}
+	isNull := false
+	isString := false
+	introducer := false
+	empty := true
+	depth := 0
+
+	for {
+		tok, err := s.next()
+		if err != nil {
+			return nil, err
+		}
+		if tok.kind == tokenEOF {
+			return values, nil
+		}
+
+		if depth == 0 && (isSymbol(tok, ",") || isSymbol(tok, ")")) {
+			if isNull && !isString {
+				values = append(values, nil)
+			} else {
+				text := value.String()
+				values = append(values, &text)
+			}
+			if isSymbol(tok, ")") {
+				return values, nil
+			}
+			value.Reset()
+			isNull, isString, introducer, empty = false, false, false, true
+			continue
+		}
+
+		switch {
+		case isSymbol(tok, "("):
+			depth++
+		case isSymbol(tok, ")"):
+			depth--
+		}
+
+		/* Keep string literals decoded, ignore casts after them */
+		if isString {
+			continue
+		}
+		if tok.kind == tokenString && (empty || introducer) {
+			value.Reset()
+			isString = true
+		}
+		if empty && isWord(tok, "NULL") {
+			isNull = true
+		}
+		/* Charset introducer before a string (_binary 'x') */
+		introducer = empty && tok.kind == tokenWord && strings.HasPrefix(tok.text, "_")
+		value.WriteString(tok.text)
+		empty = false
+	}
+}
+
+/*
+parseSet :: Track PostgreSQL standard_conforming_strings setting
+*/
+func (s *sqlScanner) parseSet() error {
+	tok, err := s.next()
+	if err != nil {
+		return err
+	}
+	if !isWord(tok, "standard_conforming_strings") {
+		return s.skipStatementFrom(tok)
+	}
+
+	for {
+		tok, err = s.next()
+		if err != nil {
+			return err
+		}
+		if tok.kind == tokenEOF || isSymbol(tok, ";") {
+			return nil
+		}
+		if tok.kind == tokenWord || tok.kind == tokenString {
+			switch strings.ToLower(tok.text) {
+			case "on":
+				s.backslash = false
+			case "off":
+				s.backslash = true
+			}
+		}
+	}
+}
+
+/*
+skipCopy :: Skip COPY statement and its inline data block
+*/
+func (s *sqlScanner) skipCopy() error {
+	fromStdin := false
+	for {
+		tok, err := s.next()
+		if err != nil {
+			return err
+		}
+		if tok.kind == tokenEOF {
+			return nil
+		}
+		if isWord(tok, "stdin") {
+			fromStdin = true
+		}
+		if isSymbol(tok, ";") {
+			break
+		}
+	}
+	if !fromStdin {
+		return nil
+	}
+
+	for {
+		line, err := s.reader.ReadString('\n')
+		if strings.TrimRight(line, "\r\n") == `\.` {
+			return nil
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+/*
+skipStatement :: Skip tokens up to the end of the statement
+*/
+func (s *sqlScanner) skipStatement() error {
+	for {
+		tok, err := s.next()
+		if err != nil {
+			return err
+		}
+		if tok.kind == tokenEOF || isSymbol(tok, ";") {
+			return nil
+		}
+	}
+}
+
+/*
+skipStatementFrom :: Skip statement unless tok already terminates it
+*/
+func (s *sqlScanner) skipStatementFrom(tok sqlToken) error {
+	if tok.kind == tokenEOF || isSymbol(tok, ";") {
+		return nil
+	}
+	return s.skipStatement()
+}
+
+/*
+nextWordSkipping :: Next token, skipping the given optional keywords
+*/
+func (s *sqlScanner) nextWordSkipping(words ...string) (sqlToken, error) {
+	for {
+		tok, err := s.next()
+		if err != nil {
+			return tok, err
+		}
+
+		optional := false
+		for _, word := range words {
+			if isWord(tok, word) {
+				optional = true
+			}
+		}
+		if !optional {
+			return tok, nil
+		}
+	}
+}
+
+/*
+readName :: Read a possibly qualified name (schema.table),
+returns the name and the token following it
+*/
+func (s *sqlScanner) readName(tok sqlToken) (string, sqlToken, error) {
+	name := ""
+	for tok.kind == tokenWord || tok.kind == tokenIdent {
+		name += tok.text
+
+		next, err := s.next()
+		if err != nil {
+			return "", next, err
+		}
+		if !isSymbol(next, ".") {
+			return name, next, nil
+		}
+		name += "."
+
+		tok, err = s.next()
+		if err != nil {
+			return "", tok, err
+		}
+	}
+
+	return name, tok, nil
+}
+
+/*
+next :: Read next token, skipping blanks and comments
+*/
+func (s *sqlScanner) next() (sqlToken, error) {
+	for {
+		c, err := s.reader.ReadByte()
+		if err == io.EOF {
+			return sqlToken{kind: tokenEOF}, nil
+		}
+		if err != nil {
+			return sqlToken{}, err
+		}
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f':
+			continue
+		case c == '#' || (c == '-' && s.peek() == '-'):
+			if _, err := s.reader.ReadString('\n'); err != nil && err != io.EOF {
+				return sqlToken{}, err
+			}
+			continue
+		case c == '/' && s.peek() == '*':
+			if err := s.skipBlockComment(); err != nil {
+				return sqlToken{}, err
+			}
+			continue
+		case c == '\'':
+			text, err := s.readString(s.backslash)
+			return sqlToken{kind: tokenString, text: text}, err
+		case c == '`' || c == '"':
+			text, err := s.readQuoted(c)
+			return sqlToken{kind: tokenIdent, text: text}, err
+		case c >= '0' && c <= '9':
+			number := s.readNumber(c)
+			/* Hex literals (mysqldump --hex-blob) are binary strings */
+			if text, ok := hexLiteral(number); ok {
+				return sqlToken{kind: tokenString, text: text}, nil
+			}
+			return sqlToken{kind: tokenNumber, text: number}, nil
+		case c == '$':
+			return s.readDollar()
+		case isWordByte(c):
+			return s.readWord(c)
+		default:
+			return sqlToken{kind: tokenSymbol, text: string(c)}, nil
+		}
+	}
+}
+
+/*
+readWord :: Read keyword or bare identifier, handles prefixed strings
+(E'...', N'...', X'...', _binary'...')
+*/
+func (s *sqlScanner) readWord(first byte) (sqlToken, error) {
+	word := []byte{first}
+	for isWordByte(s.peek()) || (s.peek() >= '0' && s.peek() <= '9') {
+		c, _ := s.reader.ReadByte()
+		word = append(word, c)
+	}
+
+	if s.peek() != '\'' {
+		return sqlToken{kind: tokenWord, text: string(word)}, nil
+	}
+
+	prefix := strings.ToUpper(string(word))
+	switch {
+	case prefix == "E":
+		s.reader.ReadByte()
+		text, err := s.readString(true)
+		return sqlToken{kind: tokenString, text: text}, err
+	case prefix == "X":
+		s.reader.ReadByte()
+		text, err := s.readString(false)
+		if decoded, decodeErr := hex.DecodeString(text); decodeErr == nil {
+			text = string(decoded)
+		}
+		return sqlToken{kind: tokenString, text: text}, err
+	case prefix == "N" || prefix == "B" || strings.HasPrefix(prefix, "_"):
+		s.reader.ReadByte()
+		text, err := s.readString(s.backslash)
+		return sqlToken{kind: tokenString, text: text}, err
+	}
+
+	return sqlToken{kind: tokenWord, text: string(word)}, nil
+}
+
+/*
+readString :: Read string literal, opening quote already consumed
+*/
+func (s *sqlScanner) readString(backslash bool) (string, error) {
+	text := strings.Builder{}
+	for {
+		c, err := s.reader.ReadByte()
+		if err == io.EOF {
+			return text.String(), io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return "", err
+		}
+
+		switch {
+		case c == '\\' && backslash:
+			n, err := s.reader.ReadByte()
+			if err != nil {
+				return text.String(), io.ErrUnexpectedEOF
+			}
+			text.WriteByte(unescapeSQL(n))
+		case c == '\'' && s.peek() == '\'':
+			s.reader.ReadByte()
+			text.WriteByte('\'')
+		case c == '\'':
+			return text.String(), nil
+		default:
+			text.WriteByte(c)
+		}
+	}
+}
+
+/*
+readQuoted :: Read quoted identifier, doubled quotes are escapes
+*/
+func (s *sqlScanner) readQuoted(quote byte) (string, error) {
+	text := strings.Builder{}
+	for {
+		c, err := s.reader.ReadByte()
+		if err == io.EOF {
+			return text.String(), io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return "", err
+		}
+		if c == quote {
+			if s.peek() != quote {
+				return text.String(), nil
+			}
+			s.reader.ReadByte()
+		}
+		text.WriteByte(c)
+	}
+}
+
+/*
+readNumber :: Read numeric literal (including hex and exponent)
+*/
+func (s *sqlScanner) readNumber(first byte) string {
+	number := []byte{first}
+	for {
+		c := s.peek()
+		last := number[len(number)-1]
+		isHex := len(number) > 1 && (number[1] == 'x' || number[1] == 'X')
+		isExponent := (c == '+' || c == '-') && (last == 'e' || last == 'E') && !isHex
+		if !isWordByte(c) && !(c >= '0' && c <= '9') && c != '.' && !isExponent {
+			return string(number)
+		}
+		s.reader.ReadByte()
+		number = append(number, c)
+	}
+}
+
+/*
+hexLiteral :: Decode 0x hex literal, false if number is not one
+*/
+func hexLiteral(number string) (string, bool) {
+	if !strings.HasPrefix(number, "0x") {
+		return "", false
+	}
+	digits := number[2:]
+	if len(digits)%2 == 1 {
+		digits = "0" + digits
+	}
+	decoded, err := hex.DecodeString(digits)
+	if err != nil || len(decoded) < 1 {
+		return "", false
+	}
+
+	return string(decoded), true
+}
+
+/*
+readDollar :: Read PostgreSQL dollar quoted string ($tag$...$tag$)
+*/
+func (s *sqlScanner) readDollar() (sqlToken, error) {
+	tag := []byte{'$'}
+	for c := s.peek(); c != '$' && (isWordByte(c) || (c >= '0' && c <= '9')); c = s.peek() {
+		c, _ := s.reader.ReadByte()
+		tag = append(tag, c)
+	}
+	if s.peek() != '$' {
+		return sqlToken{kind: tokenWord, text: string(tag)}, nil
+	}
+	s.reader.ReadByte()
+	tag = append(tag, '$')
+
+	text := []byte{}
+	for {
+		c, err := s.reader.ReadByte()
+		if err == io.EOF {
+			return sqlToken{kind: tokenString, text: string(text)}, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return sqlToken{}, err
+		}
+		text = append(text, c)
+		if len(text) >= len(tag) && string(text[len(text)-len(tag):]) == string(tag) {
+			return sqlToken{kind: tokenString, text: string(text[:len(text)-len(tag)])}, nil
+		}
+	}
+}
+
+/*
+skipBlockComment :: Skip block comment, opening slash already consumed
+*/
+func (s *sqlScanner) skipBlockComment() error {
+	s.reader.ReadByte()
+	last := byte(0)
+	for {
+		c, err := s.reader.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if last == '*' && c == '/' {
+			return nil
+		}
+		last = c
+	}
+}
+
+/*
+peek :: Peek next byte, 0 on EOF
+*/
+func (s *sqlScanner) peek() byte {
+	b, err := s.reader.Peek(1)
+	if err != nil {
+		return 0
+	}
+	return b[0]
+}
+
+/*
+unescapeSQL :: Decode MySQL backslash escape
+*/
+func unescapeSQL(c byte) byte {
+	switch c {
+	case '0':
+		return 0
+	case 'b':
+		return '\b'
+	case 'n':
+		return '\n'
+	case 'r':
+		return '\r'
+	case 't':
+		return '\t'
+	case 'Z':
+		return 0x1a
+	}
+	return c
+}
+
+/*
+isWordByte :: Check if byte can be part of a bare word
+*/
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || c >= 0x80 ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+/*
+isWord :: Check if token is the given keyword (case insensitive)
+*/
+func isWord(tok sqlToken, word string) bool {
+	return tok.kind == tokenWord && strings.EqualFold(tok.text, word)
+}
+
+/*
+isSymbol :: Check if token is the given symbol
+*/
+func isSymbol(tok sqlToken, symbol string) bool {
+	return tok.kind == tokenSymbol && tok.text == symbol
+}
+
+/*
+tableMatch :: Compare table names, schema qualifier is optional
+*/
+func tableMatch(name string, table string) bool {
+	if name == table {
+		return true
+	}
+	return strings.HasSuffix(name, "."+table) || strings.HasSuffix(table, "."+name)
+}
+
+/*
+sqlTables :: Collect tables and a few sample rows from SQL dump
+*/
+func sqlTables(r io.Reader, maxRows int) ([]*common.Table, error) {
+	tables := []*common.Table{}
+	index := map[string]*common.Table{}
+
+	scanner := newSQLScanner(r)
+	err := scanner.scan(func(row *sqlRow) {
+		table, ok := index[row.table]
+		if !ok {
+			table = &common.Table{Name: row.table, Rows: [][]string{}}
+			index[row.table] = table
+			tables = append(tables, table)
+		}
+		if len(row.columns) > len(table.Columns) {
+			table.Columns = row.columns
+		}
+		if len(table.Rows) < maxRows {
+			table.Rows = append(table.Rows, rowValues(row.values))
+		}
+	})
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+
+	/* Tables declared without rows */
+	for _, name := range scanner.order {
+		if _, ok := index[name]; !ok {
+			tables = append(tables, &common.Table{
+				Name:    name,
+				Columns: scanner.tables[name],
+				Rows:    [][]string{},
+			})
+		}
+	}
+
+	return tables, nil
+}
+
+/*
+rowValues :: Convert nullable values to strings
+*/
+func rowValues(values []*string) []string {
+	row := make([]string, len(values))
+	for i, value := range values {
+		if value != nil {
+			row[i] = *value
+		}
+	}
+	return row
+}
@@ -0,0 +1,212 @@
+package parser
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/x0e1f/dump-hub/common"
+)
+
+const usersTable = "CREATE TABLE `users` (\n" +
+	"  `id` int(11) NOT NULL AUTO_INCREMENT,\n" +
+	"  `email` varchar(255) DEFAULT NULL,\n" +
+	"  `password` varchar(64) DEFAULT 'x,y',\n" +
+	"  PRIMARY KEY (`id`),\n" +
+	"  KEY `email` (`email`)\n" +
+	") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;\n"
+
+func TestParseSQL(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  common.ParserConfig
+		input   string
+		entries []map[string]string
+		skipped []string
+	}{
+		{
+			name:  "columns from create table",
+			input: usersTable + "INSERT INTO `users` VALUES (1,'a@b.c','pw1'),(2,'d@e.f',NULL);\n",
+			entries: []map[string]string{
+				{"id": "1", "email": "a@b.c", "password": "pw1"},
+				{"id": "2", "email": "d@e.f"},
+			},
+		},
+		{
+			name:    "columns from insert",
+			input:   usersTable + "INSERT INTO users (email, `pass word`) VALUES ('a@b.c','pw');\n",
+			entries: []map[string]string{{"email": "a@b.c", "pass word": "pw"}},
+		},
+		{
+			name:    "columns by index without create table",
+			input:   "INSERT INTO t VALUES ('a','b');\n",
+			entries: []map[string]string{{"0": "a", "1": "b"}},
+		},
+		{
+			name:   "selected columns",
+			config: common.ParserConfig{Columns: "email:mail:email,2"},
+			input:  usersTable + "INSERT INTO users VALUES (1,'a@b.c','pw1'),(2,NULL,'');\n",
+			entries: []map[string]string{
+				{"mail": "a@b.c", "2": "pw1"},
+			},
+			skipped: []string{ReasonNoFields},
+		},
+		{
+			name:   "multi-row statements",
+			config: common.ParserConfig{Columns: "0"},
+			input: "INSERT INTO t VALUES ('a'),\n('b') , ( 'c' );\n" +
+				"INSERT IGNORE INTO t VALUE ('d');\nREPLACE INTO t VALUES ('e');\n",
+			entries: []map[string]string{{"0": "a"}, {"0": "b"}, {"0": "c"}, {"0": "d"}, {"0": "e"}},
+		},
+		{
+			name:   "first table by default",
+			config: common.ParserConfig{Columns: "0"},
+			input:  "INSERT INTO a VALUES ('1');\nINSERT INTO b VALUES ('2');\nINSERT INTO a VALUES ('3');\n",
+			entries: []map[string]string{
+				{"0": "1"},
+				{"0": "3"},
+			},
+		},
+		{
+			name:    "selected table with schema",
+			config:  common.ParserConfig{Table: "accounts", Columns: "0"},
+			input:   "INSERT INTO a VALUES ('1');\nINSERT INTO public.accounts VALUES ('2');\n",
+			entries: []map[string]string{{"0": "2"}},
+		},
+		{
+			name:  "backslash escapes",
+			input: `INSERT INTO t VALUES ('it\'s','a\\b','x\ny','q''q','\%');` + "\n",
+			entries: []map[string]string{
+				{"0": "it's", "1": `a\b`, "2": "x\ny", "3": "q'q", "4": "%"},
+			},
+		},
+		{
+			name: "standard conforming strings",
+			input: "SET standard_conforming_strings = on;\n" +
+				`INSERT INTO t VALUES ('a\b','it''s',E'x\ty');` + "\n" +
+				"SET standard_conforming_strings = 'off';\n" +
+				`INSERT INTO t VALUES ('a\'b');` + "\n",
+			entries: []map[string]string{
+				{"0": `a\b`, "1": "it's", "2": "x\ty"},
+				{"0": "a'b"},
+			},
+		},
+		{
+			name:  "binary literals",
+			input: `INSERT INTO t VALUES (X'61626364',0x70617373,0xabc,_binary 'b\'in',_utf8mb4'u',N'n');` + "\n",
+			entries: []map[string]string{
+				{"0": "abcd", "1": "pass", "2": "\x0a\xbc", "3": "b'in", "4": "u", "5": "n"},
+			},
+		},
+		{
+			name:  "numbers and nulls",
+			input: "INSERT INTO t VALUES (12,-3.5,1e+10,NULL,'NULL',0);\n",
+			entries: []map[string]string{
+				{"0": "12", "1": "-3.5", "2": "1e+10", "4": "NULL", "5": "0"},
+			},
+		},
+		{
+			name:  "dollar quoting",
+			input: "INSERT INTO t VALUES ($$it's$$,$tag$a$$b$tag$,$1);\n",
+			entries: []map[string]string{
+				{"0": "it's", "1": "a$$b", "2": "$1"},
+			},
+		},
+		{
+			name: "comments inside statements",
+			input: "-- dump\n# mysql\n/* header */\n" +
+				"INSERT INTO t /* inline */ VALUES -- rows\n" +
+				"('a', # value\n'b' /* multi\nline */),('--not a comment','/* nor this */');\n",
+			entries: []map[string]string{
+				{"0": "a", "1": "b"},
+				{"0": "--not a comment", "1": "/* nor this */"},
+			},
+		},
+		{
+			name:  "casts and functions",
+			input: "INSERT INTO t VALUES ('a'::text,now(),'b');\n",
+			entries: []map[string]string{
+				{"0": "a", "1": "now()", "2": "b"},
+			},
+		},
+		{
+			name: "copy blocks ignored",
+			input: "COPY t (a) FROM stdin;\nx\t'y\n\\.\n" +
+				"INSERT INTO t VALUES ('z');\n",
+			entries: []map[string]string{{"0": "z"}},
+		},
+	}
+
+	for _, test := range tests {
+		config := test.config
+		config.Format = FormatSQL
+		got, err := parseString(t, &config, test.input)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		checkResult(t, test.name, got, test.entries, test.skipped)
+	}
+}
+
+func TestParseSQLUnterminated(t *testing.T) {
+	got, err := parseString(t, &common.ParserConfig{Format: FormatSQL}, "INSERT INTO t VALUES ('a'),('b")
+	if err == nil {
+		t.Fatal("expected an error for an unterminated string")
+	}
+	checkResult(t, "unterminated", got, []map[string]string{{"0": "a"}}, nil)
+}
+
+func TestSQLTables(t *testing.T) {
+	p, err := New(&common.ParserConfig{Format: FormatSQL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := usersTable +
+		"CREATE TABLE IF NOT EXISTS public.empty (id integer, name text);\n" +
+		"INSERT INTO users VALUES (1,'a@b.c','pw1'),(2,'d@e.f',NULL);\n"
+	tables, err := p.Tables(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*common.Table{
+		{
+			Name:    "users",
+			Columns: []string{"id", "email", "password"},
+			Rows:    [][]string{{"1", "a@b.c", "pw1"}, {"2", "d@e.f", ""}},
+		},
+		{
+			Name:    "public.empty",
+			Columns: []string{"id", "name"},
+			Rows:    [][]string{},
+		},
+	}
+	if !reflect.DeepEqual(tables, want) {
+		t.Errorf("tables\n got %+v\nwant %+v", tables, want)
+	}
+}
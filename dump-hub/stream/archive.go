@@ -0,0 +1,250 @@
+package stream
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+var (
+	zipMagic = []byte{'P', 'K', 0x03, 0x04}
+	tarMagic = []byte("ustar")
+)
+
+/* Offset of the ustar magic in a tar header */
+const tarMagicOffset = 257
+
+/* Archives nested deeper are handled as plain members */
+const maxNesting = 4
+
+/*
+Member :: Regular file extracted from an archive
+*/
+type Member struct {
+	Name     string
+	Path     string
+	Checksum string
+}
+
+/*
+IsArchive :: Check if file is a zip or a (compressed) tar archive
+*/
+func IsArchive(filePath string) (bool, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	isZip, err := hasMagic(file, 0, zipMagic)
+	if err != nil || isZip {
+		return isZip, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+
+	reader, _, err := Decompress(file)
+	if err != nil {
+		return false, err
+	}
+	defer reader.Close()
+
+	return hasMagic(reader, tarMagicOffset, tarMagic)
+}
+
+/*
+Extract :: Extract archive members one at a time into dir. The handler
+owns the extracted file and is called before the next member is read.
+Members that are archives themselves are extracted too, their members
+are named after the path of the nested archive (inner.zip/users.txt)
+*/
+func Extract(filePath string, dir string, handler func(*Member) error) error {
+	return extract(filePath, "", dir, handler, 0)
+}
+
+/*
+extract :: Extract archive members with names prefixed by the path of
+the archive, depth is the nesting level of the archive
+*/
+func extract(filePath string, prefix string, dir string, handler func(*Member) error, depth int) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	memberHandler := func(member *Member) error {
+		member.Name = prefix + member.Name
+		if depth >= maxNesting {
+			return handler(member)
+		}
+
+		isArchive, err := IsArchive(member.Path)
+		if err != nil || !isArchive {
+			return handler(member)
+		}
+		defer os.Remove(member.Path)
+
+		return extract(member.Path, member.Name+"/", dir, handler, depth+1)
+	}
+
+	isZip, err := hasMagic(file, 0, zipMagic)
+	if err != nil {
+		return err
+	}
+	if isZip {
+		return extractZip(filePath, dir, memberHandler)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	reader, _, err := Decompress(file)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	return extractTar(reader, dir, memberHandler)
+}
+
+/*
+extractZip :: Extract zip archive members
+*/
+func extractZip(filePath string, dir string, handler func(*Member) error) error {
+	archive, err := zip.OpenReader(filePath)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() || isMetadata(f.Name) {
+			continue
+		}
+
+		content, err := f.Open()
+		if err != nil {
+			return err
+		}
+		member, err := extractMember(f.Name, content, dir)
+		content.Close()
+		if err != nil {
+			return err
+		}
+
+		err = handler(member)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+extractTar :: Extract tar archive members
+*/
+func extractTar(r io.Reader, dir string, handler func(*Member) error) error {
+	archive := tar.NewReader(r)
+
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg || isMetadata(header.Name) {
+			continue
+		}
+
+		member, err := extractMember(header.Name, archive, dir)
+		if err != nil {
+			return err
+		}
+
+		err = handler(member)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+/*
+extractMember :: Write member to a temporary file computing its checksum
+*/
+func extractMember(name string, r io.Reader, dir string) (*Member, error) {
+	tmpFile, err := os.CreateTemp(dir, "member-")
+	if err != nil {
+		return nil, err
+	}
+	defer tmpFile.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmpFile, hash), r)
+	if err != nil {
+		os.Remove(tmpFile.Name())
+		return nil, err
+	}
+
+	return &Member{
+		Name:     name,
+		Path:     tmpFile.Name(),
+		Checksum: hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+/*
+hasMagic :: Check magic bytes at the given offset
+*/
+func hasMagic(r io.Reader, offset int, magic []byte) (bool, error) {
+	header, err := bufio.NewReader(r).Peek(offset + len(magic))
+	if err == io.EOF || err == bufio.ErrBufferFull {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(header[offset:], magic), nil
+}
+
+/*
+isMetadata :: Check if member is archiver metadata (macOS resource forks)
+*/
+func isMetadata(name string) bool {
+	return strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(path.Base(name), "._")
+}
@@ -0,0 +1,155 @@
+package stream
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestIsArchive(t *testing.T) {
+	tests := map[string]bool{
+		"archive.zip":    true,
+		"archive.tar.gz": true,
+		"nested.zip":     true,
+		"dump.txt":       false,
+		"dump.txt.gz":    false,
+		"dump.txt.zst":   false,
+	}
+
+	for name, want := range tests {
+		got, err := IsArchive(filepath.Join("testdata", name))
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("%s: got %t, want %t", name, got, want)
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	gz, err := ioutil.ReadFile(filepath.Join("testdata", "dump.txt.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		members map[string]string
+	}{
+		{
+			name: "archive.zip",
+			members: map[string]string{
+				"users.txt":     "u1@x.y:p1\n",
+				"dump/more.txt": "u2@x.y:p2\n",
+			},
+		},
+		{
+			name: "archive.tar.gz",
+			members: map[string]string{
+				"users.txt":     "u1@x.y:p1\n",
+				"dump/more.txt": "u2@x.y:p2\n",
+			},
+		},
+		{
+			/* Compressed members are not archives, they are decompressed on import */
+			name: "nested.zip",
+			members: map[string]string{
+				"notes.txt":              "u4@x.y:p4\n",
+				"inner.tar.gz/users.txt": "u3@x.y:p3\n",
+				"dump.txt.gz":            string(gz),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		dir := t.TempDir()
+		members := map[string]string{}
+		err := Extract(filepath.Join("testdata", test.name), dir, func(member *Member) error {
+			data, err := ioutil.ReadFile(member.Path)
+			if err != nil {
+				return err
+			}
+			members[member.Name] = string(data)
+
+			return os.Remove(member.Path)
+		})
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(members, test.members) {
+			t.Errorf("%s: got members %q, want %q", test.name, members, test.members)
+		}
+
+		/* Nested archives are removed once extracted */
+		files, _ := ioutil.ReadDir(dir)
+		if len(files) > 0 {
+			t.Errorf("%s: %d files left in dir", test.name, len(files))
+		}
+	}
+}
+
+func TestExtractChecksum(t *testing.T) {
+	var member *Member
+	err := Extract(filepath.Join("testdata", "nested.zip"), t.TempDir(), func(m *Member) error {
+		if m.Name == "dump.txt.gz" {
+			member = m
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* sha256sum testdata/dump.txt.gz */
+	want := "3dc2aa58029a8a9331d8db72f2d1ded2f15488bb9ab31746b4761e6639365727"
+	if member == nil || member.Checksum != want {
+		t.Errorf("got %+v, want checksum %s", member, want)
+	}
+}
+
+func TestExtractTruncated(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "archive.tar.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp := filepath.Join(t.TempDir(), "truncated.tar.gz")
+	err = ioutil.WriteFile(fp, data[:len(data)/2], 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Extract(fp, t.TempDir(), func(member *Member) error {
+		return os.Remove(member.Path)
+	})
+	if err == nil {
+		t.Error("expected an error")
+	}
+}
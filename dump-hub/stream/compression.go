@@ -0,0 +1,107 @@
+package stream
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+	xzMagic    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+/*
+bzip2 block and end of stream magic numbers, following the BZh
+signature and the block size digit
+*/
+var (
+	bzip2Block = []byte{0x31, 0x41, 0x59, 0x26, 0x53, 0x59}
+	bzip2End   = []byte{0x17, 0x72, 0x45, 0x38, 0x50, 0x90}
+)
+
+/* Bytes peeked to detect compression */
+const magicSize = 10
+
+/*
+Decompress :: Detect compression by magic bytes and return a reader
+of the decompressed stream, along with the compression name (empty
+if the stream is not compressed)
+*/
+func Decompress(r io.Reader) (io.ReadCloser, string, error) {
+	reader := bufio.NewReader(r)
+	magic, err := reader.Peek(magicSize)
+	if err != nil && err != io.EOF {
+		return nil, "", err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gzReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, "", err
+		}
+		return gzReader, "gzip", nil
+	case isBzip2(magic):
+		return io.NopCloser(bzip2.NewReader(reader)), "bzip2", nil
+	case bytes.HasPrefix(magic, xzMagic):
+		xzReader, err := xz.NewReader(reader)
+		if err != nil {
+			return nil, "", err
+		}
+		return io.NopCloser(xzReader), "xz", nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		zstdReader, err := zstd.NewReader(reader)
+		if err != nil {
+			return nil, "", err
+		}
+		return zstdReader.IOReadCloser(), "zstd", nil
+	}
+
+	return io.NopCloser(reader), "", nil
+}
+
+/*
+isBzip2 :: Check for a full bzip2 header, the BZh signature alone is
+also the beginning of plain text
+*/
+func isBzip2(magic []byte) bool {
+	if len(magic) < magicSize || !bytes.HasPrefix(magic, bzip2Magic) {
+		return false
+	}
+	if magic[3] < '1' || magic[3] > '9' {
+		return false
+	}
+
+	return bytes.Equal(magic[4:], bzip2Block) || bytes.Equal(magic[4:], bzip2End)
+}
@@ -0,0 +1,120 @@
+package stream
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+/* printf 'a@b.c:pw\n' | bzip2 */
+var bzip2Sample = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0xfe, 0x56,
+	0xb1, 0x02, 0x00, 0x00, 0x02, 0x5d, 0x80, 0x00, 0x10, 0x00, 0x01, 0x00,
+	0x10, 0x40, 0x00, 0x38, 0x00, 0x40, 0x80, 0x20, 0x00, 0x31, 0x06, 0x4c,
+	0x41, 0x00, 0x6d, 0x23, 0x41, 0xa2, 0x1f, 0x8b, 0xb9, 0x22, 0x9c, 0x28,
+	0x48, 0x7f, 0x2b, 0x58, 0x81, 0x00,
+}
+
+/* printf '' | bzip2 */
+var bzip2Empty = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x17, 0x72, 0x45, 0x38, 0x50, 0x90, 0x00, 0x00,
+	0x00, 0x00,
+}
+
+func TestDecompress(t *testing.T) {
+	gz := &bytes.Buffer{}
+	w := gzip.NewWriter(gz)
+	w.Write([]byte("a@b.c:pw\n"))
+	w.Close()
+
+	tests := []struct {
+		name        string
+		input       []byte
+		compression string
+		output      string
+	}{
+		{"plain", []byte("a@b.c:pw\n"), "", "a@b.c:pw\n"},
+		{"short plain", []byte("BZ"), "", "BZ"},
+		{"bzip2 signature in text", []byte("BZhang@example.com:secret\n"), "", "BZhang@example.com:secret\n"},
+		{"bzip2 signature and digit in text", []byte("BZh91AY&SX and more\n"), "", "BZh91AY&SX and more\n"},
+		{"gzip", gz.Bytes(), "gzip", "a@b.c:pw\n"},
+		{"bzip2", bzip2Sample, "bzip2", "a@b.c:pw\n"},
+		{"empty bzip2", bzip2Empty, "bzip2", ""},
+	}
+
+	for _, test := range tests {
+		reader, compression, err := Decompress(bytes.NewReader(test.input))
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		output, err := ioutil.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if compression != test.compression || string(output) != test.output {
+			t.Errorf("%s: got %q (%q), want %q (%q)", test.name, output, compression, test.output, test.compression)
+		}
+	}
+}
+
+func TestDecompressFiles(t *testing.T) {
+	tests := map[string]string{
+		"dump.txt":     "",
+		"dump.txt.gz":  "gzip",
+		"dump.txt.bz2": "bzip2",
+		"dump.txt.xz":  "xz",
+		"dump.txt.zst": "zstd",
+	}
+
+	for name, want := range tests {
+		file, err := os.Open(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader, compression, err := Decompress(file)
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			file.Close()
+			continue
+		}
+		output, err := ioutil.ReadAll(reader)
+		reader.Close()
+		file.Close()
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		if compression != want || string(output) != "a@b.c:pw\nd@e.f:pw2\n" {
+			t.Errorf("%s: got %q (%q), want compression %q", name, output, compression, want)
+		}
+	}
+}
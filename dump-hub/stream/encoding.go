@@ -0,0 +1,179 @@
+package stream
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+/* Number of bytes inspected to detect the encoding */
+const sampleSize = 64 * 1024
+
+var (
+	utf8BOM    = []byte{0xef, 0xbb, 0xbf}
+	utf16LEBOM = []byte{0xff, 0xfe}
+	utf16BEBOM = []byte{0xfe, 0xff}
+)
+
+/* Supported encodings, UTF-8 is not transcoded */
+var encodings = map[string]encoding.Encoding{
+	"utf-8":        nil,
+	"utf-16le":     unicode.UTF16(unicode.LittleEndian, unicode.UseBOM),
+	"utf-16be":     unicode.UTF16(unicode.BigEndian, unicode.UseBOM),
+	"iso-8859-1":   charmap.ISO8859_1,
+	"iso-8859-15":  charmap.ISO8859_15,
+	"windows-1250": charmap.Windows1250,
+	"windows-1251": charmap.Windows1251,
+	"windows-1252": charmap.Windows1252,
+	"koi8-r":       charmap.KOI8R,
+}
+
+/*
+IsEncoding :: Check if encoding name is supported
+*/
+func IsEncoding(name string) bool {
+	_, ok := encodings[strings.ToLower(name)]
+	return ok
+}
+
+/*
+Transcode :: Return a reader of the stream converted to UTF-8, along
+with the encoding name. The encoding is detected when name is empty
+*/
+func Transcode(r io.Reader, name string) (io.Reader, string, error) {
+	reader := bufio.NewReaderSize(r, sampleSize)
+	sample, err := reader.Peek(sampleSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, "", err
+	}
+
+	name = strings.ToLower(name)
+	if len(name) < 1 {
+		name = detectEncoding(sample)
+	}
+	enc, ok := encodings[name]
+	if !ok {
+		return nil, "", fmt.Errorf("unsupported encoding: %s", name)
+	}
+
+	if enc == nil {
+		if bytes.HasPrefix(sample, utf8BOM) {
+			reader.Discard(len(utf8BOM))
+		}
+		return reader, name, nil
+	}
+
+	return transform.NewReader(reader, enc.NewDecoder()), name, nil
+}
+
+/*
+detectEncoding :: Detect encoding of a sample by BOM, UTF-16 null
+bytes, UTF-8 validity and single byte letter frequency
+*/
+func detectEncoding(sample []byte) string {
+	switch {
+	case bytes.HasPrefix(sample, utf8BOM):
+		return "utf-8"
+	case bytes.HasPrefix(sample, utf16LEBOM):
+		return "utf-16le"
+	case bytes.HasPrefix(sample, utf16BEBOM):
+		return "utf-16be"
+	}
+
+	/* ASCII text encoded as UTF-16 is half null bytes */
+	evenNulls, oddNulls := 0, 0
+	for i, b := range sample {
+		if b != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			evenNulls++
+		} else {
+			oddNulls++
+		}
+	}
+	switch {
+	case oddNulls > len(sample)/4 && oddNulls > evenNulls*4:
+		return "utf-16le"
+	case evenNulls > len(sample)/4 && evenNulls > oddNulls*4:
+		return "utf-16be"
+	}
+
+	if validUTF8(sample) {
+		return "utf-8"
+	}
+
+	/*
+		Cyrillic words in Windows-1251 are runs of high bytes, while
+		in Latin text accented letters are mostly surrounded by ASCII
+		ones. Control range 0x80-0x9f is unused in Latin-1.
+	*/
+	high, runs, control := 0, 0, 0
+	for i, b := range sample {
+		switch {
+		case b >= 0xc0:
+			high++
+			if i > 0 && sample[i-1] >= 0xc0 {
+				runs++
+			}
+		case b >= 0x80 && b < 0xa0:
+			control++
+		}
+	}
+	switch {
+	case high > 0 && runs*2 > high:
+		return "windows-1251"
+	case control > 0:
+		return "windows-1252"
+	}
+
+	return "iso-8859-1"
+}
+
+/*
+validUTF8 :: Check if sample is valid UTF-8, ignoring a rune
+truncated at the end of the sample
+*/
+func validUTF8(sample []byte) bool {
+	for i := 1; i < utf8.UTFMax && i <= len(sample); i++ {
+		if utf8.RuneStart(sample[len(sample)-i]) {
+			if !utf8.FullRune(sample[len(sample)-i:]) {
+				sample = sample[:len(sample)-i]
+			}
+			break
+		}
+	}
+
+	return utf8.Valid(sample)
+}
@@ -0,0 +1,107 @@
+package stream
+
+/*
+The MIT License (MIT)
+Copyright (c) 2021 Davide Pataracchia
+Permission is hereby granted, free of charge, to any person
+obtaining a copy of this software and associated documentation
+files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use,
+copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following
+conditions:
+The above copyright notice and this permission notice shall be
+included in all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+/*
+utf16ASCII :: ASCII text encoded as UTF-16
+*/
+func utf16ASCII(text string, bigEndian bool) []byte {
+	data := []byte{}
+	for _, c := range []byte(text) {
+		if bigEndian {
+			data = append(data, 0, c)
+		} else {
+			data = append(data, c, 0)
+		}
+	}
+
+	return data
+}
+
+func TestTranscode(t *testing.T) {
+	combo := "a@b.c:pw\nd@e.f:pw2\n"
+
+	/* A two byte rune cut by the end of the sample */
+	boundary := append(bytes.Repeat([]byte("a"), sampleSize-1), []byte("é:pw\n")...)
+
+	tests := []struct {
+		name     string
+		input    []byte
+		encoding string
+		output   string
+		detected string
+	}{
+		{name: "ascii", input: []byte(combo), output: combo, detected: "utf-8"},
+		{name: "empty", input: []byte{}, output: "", detected: "utf-8"},
+		{name: "utf-8", input: []byte("josé:pässword\n"), output: "josé:pässword\n", detected: "utf-8"},
+		{name: "utf-8 bom", input: append([]byte{0xef, 0xbb, 0xbf}, combo...), output: combo, detected: "utf-8"},
+		{name: "utf-8 rune at sample end", input: boundary, output: string(boundary), detected: "utf-8"},
+		{name: "utf-16le bom", input: append([]byte{0xff, 0xfe}, utf16ASCII(combo, false)...), output: combo, detected: "utf-16le"},
+		{name: "utf-16be bom", input: append([]byte{0xfe, 0xff}, utf16ASCII(combo, true)...), output: combo, detected: "utf-16be"},
+		{name: "utf-16le", input: utf16ASCII(combo, false), output: combo, detected: "utf-16le"},
+		{name: "utf-16be", input: utf16ASCII(combo, true), output: combo, detected: "utf-16be"},
+		{name: "latin-1", input: []byte("jos\xe9:p\xe4ss\n"), output: "josé:päss\n", detected: "iso-8859-1"},
+		{name: "windows-1252", input: []byte("\x93quoted\x94:pw\n"), output: "“quoted”:pw\n", detected: "windows-1252"},
+		{name: "windows-1251", input: []byte("\xef\xf0\xe8\xe2\xe5\xf2:\xef\xe0\xf0\xee\xeb\xfc\n"), output: "привет:пароль\n", detected: "windows-1251"},
+		{name: "explicit latin-1", input: []byte("jos\xc3\xa9\n"), encoding: "ISO-8859-1", output: "josÃ©\n", detected: "iso-8859-1"},
+		{name: "explicit koi8-r", input: []byte("\xd0\xd2\xc9\xd7\xc5\xd4\n"), encoding: "koi8-r", output: "привет\n", detected: "koi8-r"},
+		{name: "explicit utf-8", input: []byte("jos\xe9\n"), encoding: "utf-8", output: "jos\xe9\n", detected: "utf-8"},
+	}
+
+	for _, test := range tests {
+		reader, detected, err := Transcode(bytes.NewReader(test.input), test.encoding)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		output, err := ioutil.ReadAll(reader)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if detected != test.detected {
+			t.Errorf("%s: got encoding %s, want %s", test.name, detected, test.detected)
+		}
+		if string(output) != test.output {
+			t.Errorf("%s: got %q, want %q", test.name, output, test.output)
+		}
+	}
+}
+
+func TestTranscodeUnsupported(t *testing.T) {
+	_, _, err := Transcode(strings.NewReader("a:b\n"), "ebcdic")
+	if err == nil || !strings.Contains(err.Error(), "unsupported encoding") {
+		t.Errorf("got %v, want unsupported encoding error", err)
+	}
+	if IsEncoding("ebcdic") || !IsEncoding("Windows-1251") {
+		t.Error("unexpected supported encodings")
+	}
+}